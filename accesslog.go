@@ -0,0 +1,49 @@
+package grpcj
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLog is a MiddlewareFunc that logs every request to logger with the following fields:
+//
+//	http_method: the HTTP method of the request (e.g. "POST").
+//	path:        the URL path of the request (e.g. "/Add").
+//	status:      the HTTP status code of the response.
+//	size:        the size of the response body in bytes.
+//	latency_ms:  the time it took to serve the request in milliseconds.
+//	request_id:  the request ID when the RequestID middleware is used.
+//	client_ip:   the IP of the client, see TrustedProxies.
+//
+// Requests answered with a 5xx status code are logged at the error level, all others at the info level.
+func AccessLog(logger *logrus.Logger) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			fields := logrus.Fields{
+				"http_method": r.Method,
+				"path":        r.URL.Path,
+				"status":      recorder.status,
+				"size":        recorder.size,
+				"latency_ms":  float64(time.Since(start)) / float64(time.Millisecond),
+			}
+			if requestID := w.Header().Get(RequestIDHeader); requestID != "" {
+				fields["request_id"] = requestID
+			}
+			if ip, ok := ClientIPFromContext(r.Context()); ok {
+				fields["client_ip"] = ip
+			}
+			entry := logger.WithFields(fields)
+			if recorder.status >= http.StatusInternalServerError {
+				entry.Error("request")
+			} else {
+				entry.Info("request")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		server     *testServer
+		wantStatus int
+		wantSize   int
+		wantLevel  logrus.Level
+	}{
+		{&testServer{}, http.StatusOK, len(`{"sum":3}`), logrus.InfoLevel},
+		{&testServer{err: status.Error(codes.Internal, "boom")}, http.StatusInternalServerError, len("rpc error: code = Internal desc = boom\n"), logrus.ErrorLevel},
+	}
+	for _, test := range tests {
+		logger, hook := logtest.NewNullLogger()
+		serverHTTP, err := NewServer(test.server, Middleware(RequestID(), AccessLog(logger)))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		req := newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		entry := hook.LastEntry()
+		if entry == nil {
+			t.Fatal("Expect the request to be logged")
+		}
+		if entry.Level != test.wantLevel {
+			t.Errorf("Expect level: %s, Got: %s", test.wantLevel, entry.Level)
+		}
+		wantFields := logrus.Fields{"http_method": "POST", "path": "/Add", "status": test.wantStatus, "size": test.wantSize, "request_id": "abc-123"}
+		for key, want := range wantFields {
+			if entry.Data[key] != want {
+				t.Errorf("Expect %s: %v, Got: %v", key, want, entry.Data[key])
+			}
+		}
+		if _, ok := entry.Data["latency_ms"].(float64); !ok {
+			t.Errorf("Expect a latency_ms field, Got: %v", entry.Data)
+		}
+	}
+}
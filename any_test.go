@@ -0,0 +1,63 @@
+package grpcj
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type anyServer struct{}
+
+func (s *anyServer) Echo(ctx context.Context, req *typepb.Option) (*typepb.Option, error) {
+	return req, nil
+}
+
+type anyResolverFunc func(typeURL string) (proto.Message, error)
+
+func (f anyResolverFunc) Resolve(typeURL string) (proto.Message, error) { return f(typeURL) }
+
+func TestAnyFields(t *testing.T) {
+	customResolver := anyResolverFunc(func(typeURL string) (proto.Message, error) {
+		if typeURL == "example.com/ErrorInfo" {
+			return &errdetails.ErrorInfo{}, nil
+		}
+		return nil, fmt.Errorf("unknown type URL %q", typeURL)
+	})
+	tests := []struct {
+		name       string
+		options    []func(*serverOpts)
+		typeURL    string
+		wantStatus int
+	}{
+		{"registered type", nil, "type.googleapis.com/google.rpc.ErrorInfo", 200},
+		{"unknown type", nil, "example.com/ErrorInfo", 400},
+		{"custom resolver", []func(*serverOpts){AnyResolver(customResolver)}, "example.com/ErrorInfo", 200},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&anyServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("%s: Error to create the server, Error:%s", test.name, err)
+		}
+		rec := httptest.NewRecorder()
+		body := `{"name": "error", "value": {"@type": "` + test.typeURL + `", "reason": "STOCKOUT"}}`
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Echo", body))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d, Body: %s", test.name, test.wantStatus, rec.Code, rec.Body.String())
+			continue
+		}
+		if test.wantStatus != 200 {
+			continue
+		}
+		for _, want := range []string{`"@type":"` + test.typeURL + `"`, `"reason":"STOCKOUT"`} {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("%s: Expect the body to contain %s, Got: %s", test.name, want, rec.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package grpcj
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type jwtClaimsKey struct{}
+
+// JWTClaimsFromContext returns the claims of the token validated by the JWTAuth middleware.
+// It can be used with the HTTP request context in middleware as well as with the context passed to RPC methods.
+func JWTClaimsFromContext(ctx context.Context) (jwt.MapClaims, bool) {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(jwt.MapClaims)
+	return claims, ok
+}
+
+// JWTAuth is a MiddlewareFunc that enforces a valid JWT in an "Authorization: Bearer <token>" header.
+// The keyFunc returns the key used to verify the signature of the token, see jwt.Keyfunc.
+// The claims are validated according to the parser options, e.g. jwt.WithIssuer, jwt.WithAudience, jwt.WithExpirationRequired
+// or jwt.WithValidMethods. The expiry and not before claims are always validated when they are present.
+// Requests without a valid token are rejected with a 401, the claims of valid tokens can be read with JWTClaimsFromContext.
+func JWTAuth(keyFunc jwt.Keyfunc, opts ...jwt.ParserOption) MiddlewareFunc {
+	parser := jwt.NewParser(opts...)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="Restricted"`)
+
+			authorization := r.Header.Get("Authorization")
+			if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			claims := jwt.MapClaims{}
+			if _, err := parser.ParseWithClaims(authorization[len("Bearer "):], claims, keyFunc); err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, claims)))
+		})
+	}
+}
+
+// APIKeyOption configures the APIKeyAuth middleware.
+type APIKeyOption func(*apiKeyOpts)
+
+type apiKeyOpts struct {
+	header     string
+	queryParam string
+	validator  func(key string) bool
+}
+
+// APIKeyHeader sets the header the API key is read from. Default is "X-API-Key", an empty name disables the header lookup.
+func APIKeyHeader(name string) APIKeyOption {
+	return func(o *apiKeyOpts) {
+		o.header = name
+	}
+}
+
+// APIKeyQueryParam sets the query parameter the API key is read from when the header is missing.
+// Default is "api_key", an empty name disables the query parameter lookup.
+func APIKeyQueryParam(name string) APIKeyOption {
+	return func(o *apiKeyOpts) {
+		o.queryParam = name
+	}
+}
+
+// APIKeyValidator allows looking keys up dynamically, e.g. in a database. The validator is called in addition to the static keys.
+func APIKeyValidator(validator func(key string) bool) APIKeyOption {
+	return func(o *apiKeyOpts) {
+		o.validator = validator
+	}
+}
+
+// APIKeyAuth is a MiddlewareFunc that enforces a valid API key, sent in the X-API-Key header or the api_key query parameter.
+// Requests without a valid key are rejected with a 401. The keys are compared in constant time to avoid timing attacks.
+// The query parameter is removed from the request once the key is validated, so it's not decoded into the request message.
+func APIKeyAuth(validKeys []string, opts ...APIKeyOption) MiddlewareFunc {
+	o := &apiKeyOpts{header: "X-API-Key", queryParam: "api_key"}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var key string
+			if o.header != "" {
+				key = r.Header.Get(o.header)
+			}
+			if key == "" && o.queryParam != "" {
+				key = r.URL.Query().Get(o.queryParam)
+			}
+			if key == "" || !isValidAPIKey(key, validKeys, o.validator) {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			// The key is not decoded into the request message.
+			if query := r.URL.Query(); o.queryParam != "" && query.Has(o.queryParam) {
+				query.Del(o.queryParam)
+				r.URL.RawQuery = query.Encode()
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isValidAPIKey(key string, validKeys []string, validator func(key string) bool) bool {
+	valid := 0
+	// Every key is compared so the time taken doesn't depend on which key matched.
+	for _, validKey := range validKeys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), []byte(validKey))
+	}
+	if valid == 1 {
+		return true
+	}
+	return validator != nil && validator(key)
+}
@@ -0,0 +1,96 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTAuth(t *testing.T) {
+	key := []byte("secret")
+	keyFunc := func(token *jwt.Token) (interface{}, error) { return key, nil }
+	sign := func(claims jwt.MapClaims, key []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("Error to sign the token, Error:%s", err)
+		}
+		return "Bearer " + token
+	}
+	valid := jwt.MapClaims{"sub": "user-1", "iss": "auth.example.com", "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		authorization string
+		wantStatus    int
+	}{
+		{sign(valid, key), http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{"Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{sign(valid, []byte("other")), http.StatusUnauthorized},
+		{sign(jwt.MapClaims{"sub": "user-1", "iss": "auth.example.com", "exp": time.Now().Add(-time.Hour).Unix()}, key), http.StatusUnauthorized},
+		{sign(jwt.MapClaims{"sub": "user-1", "iss": "other.example.com", "exp": time.Now().Add(time.Hour).Unix()}, key), http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		server := &testServer{}
+		serverHTTP, err := NewServer(server, Middleware(JWTAuth(keyFunc, jwt.WithIssuer("auth.example.com"), jwt.WithValidMethods([]string{"HS256"}))))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		req := newJSONRequest("POST", "/Add", `{}`)
+		req.Header.Set("Authorization", test.authorization)
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%q: Expect status: %d, Got: %d", test.authorization, test.wantStatus, rec.Code)
+		}
+		if test.wantStatus != http.StatusOK {
+			continue
+		}
+		claims, ok := JWTClaimsFromContext(server.ctx)
+		if !ok || claims["sub"] != "user-1" {
+			t.Errorf("Expect the claims on the method context, Got: %v", claims)
+		}
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	dynamic := APIKeyValidator(func(key string) bool { return key == "dynamic-key" })
+	tests := []struct {
+		options    []APIKeyOption
+		method     string
+		header     string
+		target     string
+		wantStatus int
+	}{
+		{nil, "POST", "key-1", "/Add", http.StatusOK},
+		{nil, "POST", "", "/Add?api_key=key-2", http.StatusOK},
+		{nil, "POST", "", "/Add", http.StatusUnauthorized},
+		{nil, "POST", "wrong", "/Add", http.StatusUnauthorized},
+		{nil, "POST", "wrong", "/Add?api_key=key-1", http.StatusUnauthorized},
+		{[]APIKeyOption{APIKeyQueryParam("")}, "POST", "", "/Add?api_key=key-1", http.StatusUnauthorized},
+		{[]APIKeyOption{APIKeyHeader("")}, "POST", "key-1", "/Add", http.StatusUnauthorized},
+		{[]APIKeyOption{APIKeyHeader("Authorization-Key")}, "POST", "key-1", "/Add", http.StatusOK},
+		{[]APIKeyOption{dynamic}, "POST", "dynamic-key", "/Add", http.StatusOK},
+		{nil, "POST", "dynamic-key", "/Add", http.StatusUnauthorized},
+		{nil, "GET", "", "/Add?api_key=key-2", http.StatusOK},
+		{nil, "GET", "", "/Add?api_key=key-2&num_one=1", http.StatusOK},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, Middleware(APIKeyAuth([]string{"key-1", "key-2"}, test.options...)))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		req := newJSONRequest(test.method, test.target, `{}`)
+		if test.header != "" {
+			req.Header.Set("X-API-Key", test.header)
+			req.Header.Set("Authorization-Key", test.header)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%q %s %s: Expect status: %d, Got: %d %s", test.header, test.method, test.target, test.wantStatus, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package grpcj
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+// benchmarkUnaryHandler measures the per-request overhead of grpcjHandler for a small JSON request.
+func benchmarkUnaryHandler(b *testing.B, options ...func(*serverOpts)) {
+	server := &testServer{}
+	handler, err := grpcjHandler(server, "/Add", "Add", reflect.ValueOf(server).MethodByName("Add"), applyOptions(options))
+	if err != nil {
+		b.Fatal(err)
+	}
+	body := `{"num_one": 1, "num_two": 2}`
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest("POST", "/Add", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func BenchmarkUnaryHandler(b *testing.B) {
+	benchmarkUnaryHandler(b)
+}
+
+func BenchmarkUnaryHandlerPoolRequests(b *testing.B) {
+	benchmarkUnaryHandler(b, PoolRequests(true))
+}
+
+// BenchmarkResponseBuffer compares marshaling a large response into a new buffer with marshaling it into a pooled one.
+func BenchmarkResponseBuffer(b *testing.B) {
+	resp := &typepb.Type{Name: "Large"}
+	for i := 0; i < 200; i++ {
+		resp.Fields = append(resp.Fields, &typepb.Field{Name: fmt.Sprintf("field_%d", i), Number: int32(i), JsonName: fmt.Sprintf("field%d", i)})
+	}
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var buf bytes.Buffer
+			DefaultMarshaler.Marshal(&buf, resp)
+			io.Discard.Write(buf.Bytes())
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := getBuffer()
+			DefaultMarshaler.Marshal(buf, resp)
+			io.Discard.Write(buf.Bytes())
+			putBuffer(buf)
+		}
+	})
+}
+
+// BenchmarkDecodeQuery measures decoding the query parameters of a GET request into the request message,
+// with the default qson path and with DescriptorQueryDecoder.
+func BenchmarkDecodeQuery(b *testing.B) {
+	for _, test := range []struct {
+		name    string
+		options []func(*serverOpts)
+	}{
+		{"qson", nil},
+		{"descriptor", []func(*serverOpts){QueryDecoder(DescriptorQueryDecoder(false))}},
+	} {
+		httpServerOpts := applyOptions(test.options)
+		b.Run(test.name, func(b *testing.B) {
+			req := httptest.NewRequest("GET", "/Add?num_one=1&num_two=2", nil)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := decodeQuery(req, &addRequest{}, httpServerOpts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package grpcj
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultResponseCacheSize = 1000
+
+// CacheMethod enables an in-process cache of the responses of an expensive read method (e.g. CacheMethod(server.GetReport, time.Minute)).
+// The method is matched by name like MethodTimeout. Successful responses to GET requests are cached for ttl, keyed by the path
+// and the query of the request (and the X-Int64-As-String header with ClientInt64AsString), and served from the cache
+// with the headers set by the method, such as the metadata of MetadataToHeader, and Cache-Control and Age headers until they expire.
+// The cache runs after the middleware handlers, so they still authenticate every request, but the cached responses are shared
+// between all clients: only cache methods whose response depends on nothing but the path and the query.
+// See ResponseCacheSize to bound the number of cached responses.
+func CacheMethod(method interface{}, ttl time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.cacheTTLs == nil {
+			s.cacheTTLs = map[string]time.Duration{}
+		}
+		methodName := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+		s.cacheTTLs[shortMethodName(methodName)] = ttl
+	}
+}
+
+// ResponseCacheSize allows setting the maximum number of responses kept by CacheMethod, shared by all the cached methods.
+// Default is 1000, the least recently used responses are evicted first.
+func ResponseCacheSize(entries int) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.responseCacheSize = entries
+	}
+}
+
+// cachedResponse is a response body along with the headers set by the method, such as its Content-Type, the ETag set by MethodETag
+// and the metadata headers of MetadataToHeader.
+type cachedResponse struct {
+	key     string
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// responseCache is a least recently used cache of responses.
+type responseCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    *list.List
+	keys       map[string]*list.Element
+}
+
+func newResponseCache(maxEntries int) *responseCache {
+	return &responseCache{maxEntries: maxEntries, entries: list.New(), keys: map[string]*list.Element{}}
+}
+
+// get returns the response stored for key, expired responses are removed.
+func (c *responseCache) get(key string, now time.Time) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.keys[key]
+	if !ok {
+		return nil
+	}
+	resp := element.Value.(*cachedResponse)
+	if !now.Before(resp.expires) {
+		c.entries.Remove(element)
+		delete(c.keys, key)
+		return nil
+	}
+	c.entries.MoveToFront(element)
+	return resp
+}
+
+func (c *responseCache) add(resp *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.keys[resp.key]; ok {
+		element.Value = resp
+		c.entries.MoveToFront(element)
+		return
+	}
+	c.keys[resp.key] = c.entries.PushFront(resp)
+	for c.entries.Len() > c.maxEntries {
+		oldest := c.entries.Back()
+		c.entries.Remove(oldest)
+		delete(c.keys, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cacheKey identifies the response to r, responses in the protobuf wire format are cached apart from JSON responses
+// and, with ClientInt64AsString, responses with int64 strings apart from responses with int64 numbers.
+func cacheKey(r *http.Request, httpServerOpts *serverOpts) string {
+	key := protobufResponseContentType(r) + " " + r.URL.RequestURI()
+	if httpServerOpts.clientInt64AsString {
+		if asString, err := strconv.ParseBool(r.Header.Get(int64AsStringHeader)); err == nil {
+			key += " " + int64AsStringHeader + "=" + strconv.FormatBool(asString)
+		}
+	}
+	return key
+}
+
+// handler wraps the handler of a method so that its successful responses to GET requests are served from c for ttl.
+func (c *responseCache) handler(handler http.Handler, ttl time.Duration, httpServerOpts *serverOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		key := cacheKey(r, httpServerOpts)
+		now := time.Now()
+		if resp := c.get(key, now); resp != nil {
+			// The values are copied, the cached response is shared by the requests served from the cache.
+			for name, values := range resp.header {
+				w.Header()[name] = append([]string(nil), values...)
+			}
+			w.Header().Set("Age", strconv.Itoa(int(now.Sub(resp.stored).Seconds())))
+			w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(resp.expires.Sub(now).Seconds())))
+			if tag := resp.header.Get("ETag"); tag != "" && etagMatches(r, tag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(resp.body)
+			return
+		}
+
+		recorder := &cacheRecorder{ResponseWriter: w, ttl: ttl, status: http.StatusOK, preset: w.Header().Clone()}
+		handler.ServeHTTP(recorder, r)
+		if recorder.wroteHeader && recorder.status == http.StatusOK {
+			c.add(&cachedResponse{key: key, header: recorder.header, body: recorder.body.Bytes(), stored: now, expires: now.Add(ttl)})
+		}
+	})
+}
+
+// cacheRecorder writes a response through while keeping a copy of its headers and body.
+// preset holds the headers set by the middleware before the method handler is called, they are not kept.
+type cacheRecorder struct {
+	http.ResponseWriter
+	ttl         time.Duration
+	status      int
+	preset      http.Header
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *cacheRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	if status == http.StatusOK {
+		r.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(r.ttl.Seconds())))
+		// Only the headers set by the method handler are kept, the others (e.g. X-Request-ID) are set by the middleware for each request.
+		r.header = http.Header{}
+		for name, values := range r.Header() {
+			if _, ok := r.preset[name]; !ok && name != "Cache-Control" {
+				r.header[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *cacheRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.status == http.StatusOK {
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped ResponseWriter, it is used by http.ResponseController.
+func (r *cacheRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
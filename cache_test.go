@@ -0,0 +1,146 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type countingServer struct {
+	calls int64
+}
+
+// Add fails when NumOne is negative.
+func (s *countingServer) Add(ctx context.Context, req *addRequest) (*addResponse, error) {
+	atomic.AddInt64(&s.calls, 1)
+	if req.NumOne < 0 {
+		return nil, status.Error(codes.InvalidArgument, "negative number")
+	}
+	return &addResponse{Sum: req.NumOne + req.NumTwo}, nil
+}
+
+func TestCacheMethod(t *testing.T) {
+	server := &countingServer{}
+	serverHTTP, err := NewServer(server,
+		CacheMethod(server.Add, 100*time.Millisecond),
+		ResponseCacheSize(2),
+		MethodETag(server.Add),
+		AllowedHTTPMethods(map[string][]string{"/Add": {"GET", "POST"}}),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	get := func(method, url, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := newJSONRequest(method, url, `{"num_one": 1}`)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		method     string
+		url        string
+		wantStatus int
+		wantCalls  int64
+		wantCached bool
+	}{
+		{"GET", "/Add?num_one=1", http.StatusOK, 1, false},
+		{"GET", "/Add?num_one=1", http.StatusOK, 1, true},
+		{"GET", "/Add?num_one=2", http.StatusOK, 2, false},
+		{"POST", "/Add?num_one=1", http.StatusOK, 3, false},
+		{"GET", "/Add?num_one=-1", http.StatusBadRequest, 4, false},
+		{"GET", "/Add?num_one=-1", http.StatusBadRequest, 5, false},
+		// The cache holds two responses, num_one=1 was used less recently than num_one=2.
+		{"GET", "/Add?num_one=3", http.StatusOK, 6, false},
+		{"GET", "/Add?num_one=2", http.StatusOK, 6, true},
+		{"GET", "/Add?num_one=1", http.StatusOK, 7, false},
+	}
+	for i, test := range tests {
+		rec := get(test.method, test.url, "")
+		if rec.Code != test.wantStatus {
+			t.Errorf("%d %s %s: Expect status: %d, Got: %d", i, test.method, test.url, test.wantStatus, rec.Code)
+		}
+		if calls := atomic.LoadInt64(&server.calls); calls != test.wantCalls {
+			t.Errorf("%d %s %s: Expect calls: %d, Got: %d", i, test.method, test.url, test.wantCalls, calls)
+		}
+		if cached := rec.Header().Get("Age") != ""; cached != test.wantCached {
+			t.Errorf("%d %s %s: Expect cached: %t, Got Age: %q", i, test.method, test.url, test.wantCached, rec.Header().Get("Age"))
+		}
+		if test.wantStatus == http.StatusOK && test.method == "GET" && rec.Header().Get("Cache-Control") == "" {
+			t.Errorf("%d %s %s: Expect a Cache-Control header", i, test.method, test.url)
+		}
+	}
+
+	rec := get("GET", "/Add?num_one=1", "")
+	if rec.Body.String() != `{"sum":1}` || rec.Header().Get("Content-Type") != defaultContentType {
+		t.Errorf("Expect the cached response, Got: %q %s", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	if rec = get("GET", "/Add?num_one=1", rec.Header().Get("ETag")); rec.Code != http.StatusNotModified {
+		t.Errorf("Expect a 304 for a cached response, Got: %d", rec.Code)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	calls := atomic.LoadInt64(&server.calls)
+	if rec = get("GET", "/Add?num_one=1", ""); rec.Header().Get("Age") != "" || atomic.LoadInt64(&server.calls) != calls+1 {
+		t.Errorf("Expect the expired response to be computed again")
+	}
+}
+
+func TestCacheMethodHeaders(t *testing.T) {
+	server := &countingServer{}
+	serverHTTP, err := NewServer(server, CacheMethod(server.Add, time.Minute), ClientInt64AsString(true))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		int64AsString string
+		wantBody      string
+	}{
+		{"", `{"sum":1}`},
+		{"true", `{"sum":"1"}`},
+		{"", `{"sum":1}`},
+		{"true", `{"sum":"1"}`},
+	}
+	for i, test := range tests {
+		req := newJSONRequest("GET", "/Add?num_one=1", "")
+		if test.int64AsString != "" {
+			req.Header.Set("X-Int64-As-String", test.int64AsString)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Body.String() != test.wantBody {
+			t.Errorf("%d X-Int64-As-String %q: Expect: %s, Got: %s", i, test.int64AsString, test.wantBody, rec.Body.String())
+		}
+	}
+	if calls := atomic.LoadInt64(&server.calls); calls != 2 {
+		t.Errorf("Expect calls: 2, Got: %d", calls)
+	}
+
+	// The metadata headers set by the method are served from the cache too.
+	metadataServer := &metadataServer{}
+	serverHTTP, err = NewServer(metadataServer, CacheMethod(metadataServer.Add, time.Minute))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("GET", "/Add", ""))
+		if rec.Header().Get("X-Next-Cursor") != "abc" || rec.Header().Get("X-Ratelimit-Remaining") != "9" {
+			t.Errorf("%d: Expect the metadata headers, Got: %v", i, rec.Header())
+		}
+		if cached := rec.Header().Get("Age") != ""; cached != (i > 0) {
+			t.Errorf("%d: Expect cached: %t, Got Age: %q", i, i > 0, rec.Header().Get("Age"))
+		}
+		// Changing the headers of a response doesn't change the cached response.
+		rec.Header()["X-Next-Cursor"][0] = "changed"
+	}
+}
@@ -0,0 +1,137 @@
+package grpcj
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies allows resolving the IP of clients from the Forwarded or X-Forwarded-For headers set by the given proxies,
+// identified by IP addresses or CIDR ranges (e.g. "10.0.0.0/8"). The addresses of the headers are walked from the last one,
+// added by the proxy the request comes from, and the first address that isn't a trusted proxy is the client IP.
+// Addresses that are not trusted proxies can't be spoofed by clients, since they are never skipped.
+// Invalid entries make Serve and NewServer return an error.
+//
+// The client IP is used by RateLimit, AccessLog and the peer.Peer of RPC methods, and can be read with ClientIPFromContext.
+// When neither TrustedProxies nor TrustedProxyCount is set, the headers are ignored and the client IP is the remote address.
+func TrustedProxies(proxies []string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		for _, proxy := range proxies {
+			cidr := proxy
+			if !strings.Contains(proxy, "/") {
+				if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+					cidr += "/32"
+				} else {
+					cidr += "/128"
+				}
+			}
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				s.trustedProxiesErr = fmt.Errorf("grpcj: invalid trusted proxy %q: %v", proxy, err)
+				continue
+			}
+			s.trustedProxies = append(s.trustedProxies, network)
+		}
+	}
+}
+
+// TrustedProxyCount allows resolving the IP of clients from the Forwarded or X-Forwarded-For headers when the requests
+// always go through the given number of proxies, e.g. 2 behind two layers of load balancers. The client IP is the address
+// added by the outermost proxy, the addresses before it may have been sent by the client and are ignored.
+// It can be combined with TrustedProxies, a hop is then trusted if either applies.
+func TrustedProxyCount(count int) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.trustedProxyCount = count
+	}
+}
+
+type clientIPKey struct{}
+
+// ClientIPFromContext returns the IP of the client resolved with TrustedProxies and TrustedProxyCount.
+// It can be used with the HTTP request context in middleware as well as with the context passed to RPC methods.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+	return ip, ok
+}
+
+// trustsProxy reports whether the hop at the given distance from the server, 0 being the remote address, is a trusted proxy.
+func (s *serverOpts) trustsProxy(ip net.IP, distance int) bool {
+	if distance < s.trustedProxyCount {
+		return true
+	}
+	for _, network := range s.trustedProxies {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client that sent r, walking the forwarded addresses back from the remote address
+// as long as they are trusted proxies.
+func (s *serverOpts) clientIP(r *http.Request) string {
+	remote := remoteHost(r)
+	if len(s.trustedProxies) == 0 && s.trustedProxyCount == 0 {
+		return remote
+	}
+	hops := forwardedFor(r)
+	client := remote
+	for distance := 0; distance <= len(hops); distance++ {
+		var ip net.IP
+		if distance > 0 {
+			if ip = net.ParseIP(hops[len(hops)-distance]); ip == nil {
+				// e.g. "unknown" or an obfuscated identifier, the nearest address is used instead.
+				break
+			}
+			client = ip.String()
+		} else {
+			ip = net.ParseIP(remote)
+		}
+		if !s.trustsProxy(ip, distance) {
+			break
+		}
+	}
+	return client
+}
+
+// remoteHost returns the host of the remote address of r, or the whole address when it has no port (e.g. on a Unix domain socket).
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns the addresses recorded by proxies in the Forwarded header (RFC 7239) or, without it, in X-Forwarded-For,
+// from the original client to the last proxy. Ports and the brackets of IPv6 addresses are removed.
+func forwardedFor(r *http.Request) []string {
+	var hops []string
+	if forwarded := r.Header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, element := range strings.Split(strings.Join(forwarded, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hops = append(hops, stripPort(strings.Trim(value, `"`)))
+				}
+			}
+		}
+		return hops
+	}
+	for _, address := range strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			hops = append(hops, stripPort(address))
+		}
+	}
+	return hops
+}
+
+// stripPort removes the port and the brackets from a forwarded address, e.g. "[2001:db8::17]:4711" or "192.0.2.60:80".
+func stripPort(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+}
@@ -0,0 +1,68 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		options      []func(*serverOpts)
+		remoteAddr   string
+		forwardedFor string
+		forwarded    string
+		want         string
+	}{
+		{nil, "10.0.0.1:1234", "1.1.1.1", "", "10.0.0.1"},
+		{[]func(*serverOpts){TrustedProxyCount(1)}, "10.0.0.1:1234", "6.6.6.6, 1.1.1.1", "", "1.1.1.1"},
+		{[]func(*serverOpts){TrustedProxyCount(2)}, "10.0.0.1:1234", "6.6.6.6, 1.1.1.1, 10.0.0.2", "", "1.1.1.1"},
+		{[]func(*serverOpts){TrustedProxyCount(2)}, "10.0.0.1:1234", "1.1.1.1", "", "1.1.1.1"},
+		{[]func(*serverOpts){TrustedProxyCount(1)}, "10.0.0.1:1234", "", "", "10.0.0.1"},
+		{[]func(*serverOpts){TrustedProxies([]string{"10.0.0.0/8"})}, "10.0.0.1:1234", "6.6.6.6, 1.1.1.1, 10.0.0.2", "", "1.1.1.1"},
+		{[]func(*serverOpts){TrustedProxies([]string{"10.0.0.0/8"})}, "1.1.1.1:1234", "6.6.6.6", "", "1.1.1.1"},
+		{[]func(*serverOpts){TrustedProxies([]string{"10.0.0.1", "10.0.0.2"})}, "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{[]func(*serverOpts){TrustedProxies([]string{"10.0.0.0/8"})}, "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{[]func(*serverOpts){TrustedProxyCount(1)}, "10.0.0.1:1234", "1.1.1.1", `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`, "2001:db8:cafe::17"},
+		{[]func(*serverOpts){TrustedProxyCount(2)}, "10.0.0.1:1234", "", `for=192.0.2.60;proto=http, for=unknown`, "10.0.0.1"},
+		{[]func(*serverOpts){TrustedProxyCount(1)}, "@", "1.1.1.1", "", "1.1.1.1"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		if test.forwarded != "" {
+			req.Header.Set("Forwarded", test.forwarded)
+		}
+		if got := applyOptions(test.options).clientIP(req); got != test.want {
+			t.Errorf("%s %q %q: Expect: %s, Got: %s", test.remoteAddr, test.forwardedFor, test.forwarded, test.want, got)
+		}
+	}
+}
+
+func TestClientIPFromContext(t *testing.T) {
+	var got string
+	record := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = ClientIPFromContext(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	}
+	serverHTTP, err := NewServer(&testServer{}, Middleware(record), TrustedProxyCount(1))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	req := newJSONRequest("POST", "/Add", `{}`)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1")
+	serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "1.1.1.1" {
+		t.Errorf("Expect: 1.1.1.1, Got: %s", got)
+	}
+
+	if _, err := NewServer(&testServer{}, TrustedProxies([]string{"not an address"})); err == nil {
+		t.Error("Expect an error for an invalid trusted proxy")
+	}
+}
@@ -0,0 +1,135 @@
+package grpcj
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// httpClientStream is a grpc.ServerStream that receives the request messages from the lines of a newline-delimited JSON body
+// and records the response message sent with SendAndClose.
+type httpClientStream struct {
+	*serverTransportStream
+	ctx            context.Context
+	body           *bufio.Reader
+	httpServerOpts *serverOpts
+	methodName     string
+	line           int
+	recvErr        error
+	validationErr  error
+	resp           proto.Message
+}
+
+func (s *httpClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *httpClientStream) SetTrailer(md metadata.MD) {
+	s.serverTransportStream.SetTrailer(md)
+}
+
+// nextLine returns the next line of the body that isn't blank, the last line doesn't need to end with a newline.
+func (s *httpClientStream) nextLine() ([]byte, error) {
+	for {
+		line, err := s.body.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			s.line++
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.line++
+	}
+}
+
+// RecvMsg decodes the next line of the body into m, it returns io.EOF once all the lines have been received.
+func (s *httpClientStream) RecvMsg(m interface{}) error {
+	if s.recvErr != nil {
+		return status.Error(codes.InvalidArgument, s.recvErr.Error())
+	}
+	line, err := s.nextLine()
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err == nil {
+		err = unmarshalerFor(s.httpServerOpts.unmarshaler, m).Unmarshal(bytes.NewReader(line), m)
+	}
+	if err != nil {
+		decodeErr := newDecodeError(err)
+		s.recvErr = &DecodeError{Reason: decodeErr.Reason, Err: fmt.Errorf("line %d: %w", s.line, err)}
+		return status.Error(codes.InvalidArgument, s.recvErr.Error())
+	}
+	if err := s.httpServerOpts.validate(s.ctx, s.methodName, m); err != nil {
+		s.validationErr = err
+		return err
+	}
+	return nil
+}
+
+// SendMsg records the response message, client-streaming methods send exactly one with SendAndClose.
+func (s *httpClientStream) SendMsg(m interface{}) error {
+	resp, ok := m.(proto.Message)
+	if !ok {
+		return errors.New("response is not a protobuf message")
+	}
+	s.resp = resp
+	return nil
+}
+
+func clientStreamHandler(endpoint string, grpcServer interface{}, streamDesc *grpc.StreamDesc, httpServerOpts *serverOpts) http.HandlerFunc {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !httpServerOpts.isAllowedHTTPMethod(endpoint, r.Method) {
+			writeMethodNotAllowed(w, endpoint, httpServerOpts)
+			return
+		}
+
+		limitRequestBody(w, r, httpServerOpts)
+		defer r.Body.Close()
+		ctx := incomingContext(r.Context(), r, httpServerOpts)
+		stream := &httpClientStream{
+			serverTransportStream: &serverTransportStream{method: endpoint},
+			body:                  bufio.NewReader(r.Body),
+			httpServerOpts:        httpServerOpts,
+			methodName:            streamDesc.StreamName,
+		}
+		stream.ctx = grpc.NewContextWithServerTransportStream(ctx, stream.serverTransportStream)
+
+		start := time.Now()
+		err := contextError(streamDesc.Handler(grpcServer, stream))
+		httpServerOpts.metrics.observeRPC(streamDesc.StreamName, status.Code(err), time.Since(start))
+		if httpServerOpts.metadataToHeader != nil {
+			stream.writeHeaders(w.Header(), httpServerOpts.metadataToHeader)
+		}
+
+		if stream.recvErr != nil {
+			writeDecodeError(w, stream.recvErr, httpServerOpts)
+			return
+		}
+		// Invalid requests are answered with a JSON error like with unary methods.
+		if err != nil && stream.validationErr != nil {
+			writeJSONStatus(w, status.Convert(stream.validationErr), httpServerOpts)
+			return
+		}
+		if err == nil && isNilMessage(stream.resp) {
+			err = status.Errorf(codes.Internal, "method %s returned without sending a response", streamDesc.StreamName)
+		}
+		if err != nil {
+			writeError(stream.ctx, w, r, err, httpServerOpts)
+			return
+		}
+		writeResponse(w, r, stream.resp, httpServerOpts.requestMarshaler(r), streamDesc.StreamName, httpServerOpts)
+	})
+	return handler
+}
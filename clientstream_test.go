@@ -0,0 +1,119 @@
+package grpcj
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type sumServer struct{}
+
+// Sum adds up the numbers of all the requests it receives, it fails when a request has a negative NumOne.
+func (s *sumServer) Sum(stream SumService_SumServer) error {
+	var sum int64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&addResponse{Sum: sum})
+		}
+		if err != nil {
+			return err
+		}
+		if req.NumOne < 0 {
+			return status.Error(codes.InvalidArgument, "negative number")
+		}
+		sum += req.NumOne + req.NumTwo
+	}
+}
+
+// The following mirrors the code generated by protoc-gen-go-grpc for a client-streaming method.
+
+type SumService_SumServer interface {
+	SendAndClose(*addResponse) error
+	Recv() (*addRequest, error)
+	grpc.ServerStream
+}
+
+type sumServiceSumServer struct {
+	grpc.ServerStream
+}
+
+func (x *sumServiceSumServer) SendAndClose(m *addResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *sumServiceSumServer) Recv() (*addRequest, error) {
+	m := new(addRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _SumService_Sum_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(*sumServer).Sum(&sumServiceSumServer{stream})
+}
+
+var sumServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.SumService",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Sum",
+			Handler:       _SumService_Sum_Handler,
+			ClientStreams: true,
+		},
+	},
+}
+
+func TestClientStream(t *testing.T) {
+	serverHTTP, err := NewServer(&sumServer{}, ServiceDesc(&sumServiceDesc))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantBody   string
+		wantReason string
+	}{
+		{"{\"num_one\": 1}\n{\"num_one\": 2, \"num_two\": 3}\n", http.StatusOK, `{"sum":6}`, ""},
+		{"{\"num_one\": 1}\n\n  \n{\"num_one\": 2}", http.StatusOK, `{"sum":3}`, ""},
+		{"", http.StatusOK, `{"sum":0}`, ""},
+		{"{\"num_one\": 1}\n{\"num_one\": 2", http.StatusBadRequest, "", ReasonInvalidJSON},
+		{"{\"num_one\": 1}\n{\"nope\": 2}\n{\"num_one\": 3}\n", http.StatusBadRequest, "", ReasonUnknownField},
+		{"{\"num_one\": -1}\n", http.StatusBadRequest, "", ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Sum", test.body))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%q: Expect status: %d, Got: %d %s", test.body, test.wantStatus, rec.Code, rec.Body.String())
+			continue
+		}
+		if test.wantBody != "" && rec.Body.String() != test.wantBody {
+			t.Errorf("%q: Expect: %s, Got: %s", test.body, test.wantBody, rec.Body.String())
+		}
+		if test.wantReason != "" {
+			var body DecodeErrorBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error != test.wantReason {
+				t.Errorf("%q: Expect reason %s, Got: %s", test.body, test.wantReason, rec.Body.String())
+			}
+		}
+	}
+
+	routes, err := Routes(&sumServer{}, ServiceDesc(&sumServiceDesc))
+	if err != nil {
+		t.Fatalf("Error to list the routes, Error:%s", err)
+	}
+	if len(routes) != 1 || !routes[0].ClientStreaming || routes[0].RequestType.String() != "*grpcj.addRequest" || routes[0].ResponseType.String() != "*grpcj.addResponse" {
+		t.Errorf("Expect a client-streaming route, Got: %+v", routes)
+	}
+}
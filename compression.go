@@ -0,0 +1,159 @@
+package grpcj
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+const defaultCompressionMinSize = 1024
+
+// Compression allows gzip compressing responses for clients that send an "Accept-Encoding: gzip" header.
+// The level is one of the compress/gzip levels (e.g. gzip.DefaultCompression or gzip.BestSpeed), building the server fails otherwise.
+// Only responses of at least the minimum size are compressed, see CompressionMinSize.
+// Streaming responses (newline-delimited JSON and Server-Sent Events) are never compressed.
+func Compression(level int) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.compression = true
+		s.compressionLevel = level
+	}
+}
+
+// isValidCompressionLevel reports whether level is accepted by gzip.NewWriterLevel.
+func isValidCompressionLevel(level int) bool {
+	return level >= gzip.HuffmanOnly && level <= gzip.BestCompression
+}
+
+// CompressionMinSize allows setting the minimum response size in bytes for responses to be compressed. Default is 1024.
+func CompressionMinSize(size int) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.compressionMinSize = size
+	}
+}
+
+// compressHandler wraps handler so that responses are gzip compressed when the client accepts it.
+func compressHandler(handler http.Handler, level, minSize int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r) || isWebSocketUpgrade(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, level: level, minSize: minSize, status: http.StatusOK}
+		defer gw.close()
+		handler.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.Split(encoding, ";")[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the response until it reaches the minimum size and then compresses it.
+// Responses that stay below the minimum size, streaming responses and already encoded responses are written as is.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	level       int
+	minSize     int
+	status      int
+	buf         []byte
+	gz          *gzip.Writer
+	passthrough bool
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	if !w.passthrough && !w.compressible() {
+		w.startPassthrough()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush sends any buffered data to the client. A response that is flushed before it is compressed is not compressed at all.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	} else if !w.passthrough {
+		w.startPassthrough()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *gzipResponseWriter) compressible() bool {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	switch strings.Split(header.Get("Content-Type"), ";")[0] {
+	case "application/x-ndjson", "text/event-stream":
+		return false
+	}
+	return true
+}
+
+func (w *gzipResponseWriter) writeHeader() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *gzipResponseWriter) startPassthrough() {
+	w.passthrough = true
+	w.writeHeader()
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *gzipResponseWriter) startGzip() error {
+	header := w.Header()
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.writeHeader()
+
+	gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	if err != nil {
+		return err
+	}
+	w.gz = gz
+	_, err = w.gz.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close writes out the remainder of the response.
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+		return
+	}
+	if !w.passthrough {
+		w.startPassthrough()
+	}
+}
@@ -0,0 +1,63 @@
+package grpcj
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		minSize        int
+		path           string
+		body           string
+		wantGzip       bool
+		wantBody       string
+	}{
+		{"gzip", 1, "/Add", `{"num_one": 1, "num_two": 2}`, true, `{"sum":3}`},
+		{"deflate, gzip;q=0.5", 1, "/Add", `{"num_one": 1, "num_two": 2}`, true, `{"sum":3}`},
+		{"", 1, "/Add", `{"num_one": 1, "num_two": 2}`, false, `{"sum":3}`},
+		{"gzip", 100, "/Add", `{"num_one": 1, "num_two": 2}`, false, `{"sum":3}`},
+		{"gzip", 1, "/Count", `{"num_one": 2}`, false, "{\"sum\":1}\n{\"sum\":2}\n"},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, ServiceDesc(&testServiceDesc), Compression(gzip.BestSpeed), CompressionMinSize(test.minSize))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		req := newJSONRequest("POST", test.path, test.body)
+		req.Header.Set("Accept-Encoding", test.acceptEncoding)
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+
+		body := rec.Body.String()
+		if gotGzip := rec.Header().Get("Content-Encoding") == "gzip"; gotGzip != test.wantGzip {
+			t.Errorf("%s with %q: Expect gzip: %t, Got: %t", test.path, test.acceptEncoding, test.wantGzip, gotGzip)
+		} else if gotGzip {
+			gz, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("Error to read the gzip body, Error:%s", err)
+			}
+			b, _ := ioutil.ReadAll(gz)
+			body = string(b)
+		}
+		if body != test.wantBody {
+			t.Errorf("%s with %q: Expect: %q, Got: %q", test.path, test.acceptEncoding, test.wantBody, body)
+		}
+	}
+}
+
+func TestCompressionLevel(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly, gzip.DefaultCompression, gzip.NoCompression, gzip.BestCompression} {
+		if _, err := NewServer(&testServer{}, Compression(level)); err != nil {
+			t.Errorf("Expect no error for the level %d, Got: %s", level, err)
+		}
+	}
+	for _, level := range []int{gzip.HuffmanOnly - 1, gzip.BestCompression + 1, 42} {
+		if _, err := NewServer(&testServer{}, Compression(level)); err == nil {
+			t.Errorf("Expect an error for the level %d", level)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package grpcj
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORS allows cross-origin requests from browsers. The Access-Control-Allow-* headers are set for requests from the given origins,
+// a "*" origin allows any origin. The methods and headers are the ones allowed in preflight requests,
+// they default to POST and GET and to the Content-Type header when empty.
+// Preflight OPTIONS requests are answered with a 204 before reaching the middleware handlers and the RPC method.
+// Use CORSCredentials to allow cookies and authorization headers.
+func CORS(origins []string, methods []string, headers []string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.cors = &corsOpts{origins: origins, methods: methods, headers: headers}
+		if len(s.cors.methods) == 0 {
+			s.cors.methods = defaultHTTPMethods
+		}
+		if len(s.cors.headers) == 0 {
+			s.cors.headers = []string{"Content-Type"}
+		}
+	}
+}
+
+// CORSCredentials allows sending the Access-Control-Allow-Credentials header on CORS requests. Default is false.
+// With credentials allowed, a "*" origin echoes the origin of the request since browsers reject a wildcard with credentials.
+func CORSCredentials(allow bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.corsCredentials = allow
+	}
+}
+
+type corsOpts struct {
+	origins []string
+	methods []string
+	headers []string
+}
+
+func (c *corsOpts) allowOrigin(origin string, credentials bool) string {
+	for _, allowed := range c.origins {
+		if allowed == "*" {
+			if credentials {
+				return origin
+			}
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// corsHandler wraps handler so that CORS headers are set and preflight requests are answered.
+func corsHandler(handler http.Handler, c *corsOpts, credentials bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		allowOrigin := c.allowOrigin(origin, credentials)
+		if allowOrigin != "" {
+			header.Set("Access-Control-Allow-Origin", allowOrigin)
+			if credentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if !preflight {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if allowOrigin != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(c.headers, ", "))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,65 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	rejectAll := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	tests := []struct {
+		options         []func(*serverOpts)
+		method          string
+		origin          string
+		wantStatus      int
+		wantOrigin      string
+		wantMethods     string
+		wantCredentials string
+	}{
+		{[]func(*serverOpts){CORS([]string{"https://app.example.com"}, nil, nil)}, "OPTIONS", "https://app.example.com", http.StatusNoContent, "https://app.example.com", "POST, GET", ""},
+		{[]func(*serverOpts){CORS([]string{"https://app.example.com"}, []string{"POST"}, nil)}, "OPTIONS", "https://evil.example.com", http.StatusNoContent, "", "", ""},
+		{[]func(*serverOpts){CORS([]string{"*"}, nil, nil)}, "OPTIONS", "https://any.example.com", http.StatusNoContent, "*", "POST, GET", ""},
+		{[]func(*serverOpts){CORS([]string{"*"}, nil, nil), CORSCredentials(true)}, "OPTIONS", "https://any.example.com", http.StatusNoContent, "https://any.example.com", "POST, GET", "true"},
+		{[]func(*serverOpts){CORS([]string{"*"}, nil, nil)}, "POST", "https://any.example.com", http.StatusUnauthorized, "*", "", ""},
+		{[]func(*serverOpts){CORS([]string{"*"}, nil, nil)}, "POST", "", http.StatusUnauthorized, "", "", ""},
+		{nil, "OPTIONS", "https://app.example.com", http.StatusUnauthorized, "", "", ""},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, append(test.options, Middleware(rejectAll))...)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		req := newJSONRequest(test.method, "/Add", `{}`)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		if test.method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s from %q: Expect status: %d, Got: %d", test.method, test.origin, test.wantStatus, rec.Code)
+		}
+		wantHeaders := map[string]string{
+			"Access-Control-Allow-Origin":      test.wantOrigin,
+			"Access-Control-Allow-Methods":     test.wantMethods,
+			"Access-Control-Allow-Credentials": test.wantCredentials,
+		}
+		for name, want := range wantHeaders {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("%s from %q: Expect %s: %q, Got: %q", test.method, test.origin, name, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package grpcj
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/zang-cloud/grpc-json/jsonpb"
+)
+
+// Reasons of a DecodeError, written in the "error" field of the response to requests that can't be decoded.
+const (
+	// ReasonInvalidJSON is for a body that is not valid JSON.
+	ReasonInvalidJSON = "invalid_json"
+	// ReasonUnknownField is for a field or query parameter that is not in the request message, see AllowUnknownFields.
+	ReasonUnknownField = "unknown_field"
+	// ReasonTypeMismatch is for a JSON value of the wrong type for its field, e.g. a string for an int64 field.
+	ReasonTypeMismatch = "type_mismatch"
+	// ReasonInvalidValue is for a value of the right type that can't be used for its field, e.g. an unknown enum name.
+	ReasonInvalidValue = "invalid_value"
+	// ReasonDuplicateKey is for a JSON object with the same key more than once, see RejectDuplicateJSONKeys.
+	ReasonDuplicateKey = "duplicate_key"
+	// ReasonInvalidProtobuf is for a body in the protobuf wire format that can't be unmarshaled.
+	ReasonInvalidProtobuf = "invalid_protobuf"
+	// ReasonBodyTooLarge is for a body larger than MaxRequestBodySize, answered with a 413.
+	ReasonBodyTooLarge = "body_too_large"
+)
+
+// DecodeError is an error decoding the request into the request message, before the RPC method is called.
+// It is answered with a 400 (or a 413 for ReasonBodyTooLarge) and a JSON body such as:
+//
+//	{"error": "type_mismatch", "detail": "num_one: cannot unmarshal string \"abc\" into int64", "field": "num_one"}
+type DecodeError struct {
+	// Reason is one of the Reason constants.
+	Reason string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrorBody is the JSON body written for a DecodeError.
+type DecodeErrorBody struct {
+	Error  string `json:"error"`
+	Detail string `json:"detail"`
+	// Field is the path of the invalid field in the JSON body (e.g. "user.emails[1]"), when it is known.
+	Field string `json:"field,omitempty"`
+}
+
+// newDecodeError returns err as a *DecodeError, with a reason derived from the type of the error unless it already is one.
+func newDecodeError(err error) *DecodeError {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		return decodeErr
+	}
+	return &DecodeError{Reason: decodeErrorReason(err), Err: err}
+}
+
+func decodeErrorReason(err error) string {
+	var (
+		maxBytesErr     *http.MaxBytesError
+		unknownFieldErr *jsonpb.UnknownFieldError
+		unknownParamErr unknownQueryParamError
+		duplicateErr    *duplicateKeyError
+		typeErr         *json.UnmarshalTypeError
+		syntaxErr       *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return ReasonBodyTooLarge
+	case errors.As(err, &unknownFieldErr), errors.As(err, &unknownParamErr):
+		return ReasonUnknownField
+	case errors.As(err, &duplicateErr):
+		return ReasonDuplicateKey
+	case errors.As(err, &typeErr):
+		return ReasonTypeMismatch
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return ReasonInvalidJSON
+	}
+	return ReasonInvalidValue
+}
+
+// writeDecodeError writes an error returned by decodeRequest, which is a 413 if the body was too large and a 400 otherwise.
+// The body is a DecodeErrorBody with the JSON error content type, see ErrorContentType.
+func writeDecodeError(w http.ResponseWriter, err error, httpServerOpts *serverOpts) {
+	decodeErr := newDecodeError(err)
+	httpStatus := http.StatusBadRequest
+	if decodeErr.Reason == ReasonBodyTooLarge {
+		httpStatus = http.StatusRequestEntityTooLarge
+	}
+	body := DecodeErrorBody{Error: decodeErr.Reason, Detail: decodeErr.Error()}
+	var fieldErr *jsonpb.FieldError
+	if errors.As(err, &fieldErr) {
+		body.Field = fieldErr.Path
+	}
+	resp, _ := json.Marshal(body)
+	w.Header().Set("Content-Type", httpServerOpts.jsonErrorContentType())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpStatus)
+	w.Write(resp)
+}
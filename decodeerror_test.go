@@ -0,0 +1,91 @@
+package grpcj
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zang-cloud/grpc-json/jsonpb"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDecodeErrors(t *testing.T) {
+	protobufReq := httptest.NewRequest("POST", "/Add", bytes.NewReader([]byte{0xff}))
+	protobufReq.Header.Set("Content-Type", ContentTypeProtobuf)
+	tests := []struct {
+		req        *http.Request
+		options    []func(*serverOpts)
+		wantStatus int
+		wantReason string
+	}{
+		{newJSONRequest("POST", "/Add", `{"num_one": 1,`), nil, http.StatusBadRequest, ReasonInvalidJSON},
+		{newJSONRequest("POST", "/Add", `{"num_one" 1}`), nil, http.StatusBadRequest, ReasonInvalidJSON},
+		{newJSONRequest("POST", "/Add", `{"nope": 1}`), nil, http.StatusBadRequest, ReasonUnknownField},
+		{newJSONRequest("POST", "/Add", `{"num_one": "abc"}`), nil, http.StatusBadRequest, ReasonTypeMismatch},
+		{newJSONRequest("POST", "/Add", `{"num_one": true}`), nil, http.StatusBadRequest, ReasonTypeMismatch},
+		{newJSONRequest("POST", "/Add", `{"num_one": 1, "num_one": 2}`), []func(*serverOpts){RejectDuplicateJSONKeys(true)}, http.StatusBadRequest, ReasonDuplicateKey},
+		{newJSONRequest("POST", "/Add", `{"num_one": 1000000}`), []func(*serverOpts){MaxRequestBodySize(4)}, http.StatusRequestEntityTooLarge, ReasonBodyTooLarge},
+		{newJSONRequest("GET", "/Add?nope=1", ""), []func(*serverOpts){QueryDecoder(DescriptorQueryDecoder(false))}, http.StatusBadRequest, ReasonUnknownField},
+		{newJSONRequest("GET", "/Add?num_one=x", ""), []func(*serverOpts){QueryDecoder(DescriptorQueryDecoder(false))}, http.StatusBadRequest, ReasonInvalidValue},
+		{protobufReq, nil, http.StatusBadRequest, ReasonInvalidProtobuf},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, test.req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s %s: Expect status: %d, Got: %d", test.req.Method, test.req.URL, test.wantStatus, rec.Code)
+		}
+		var body DecodeErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s %s: Expect a JSON body, Got: %s", test.req.Method, test.req.URL, rec.Body.String())
+			continue
+		}
+		if body.Error != test.wantReason || body.Detail == "" {
+			t.Errorf("%s %s: Expect reason %s with a detail, Got: %s", test.req.Method, test.req.URL, test.wantReason, rec.Body.String())
+		}
+	}
+}
+
+func TestFieldPathErrors(t *testing.T) {
+	tests := []struct {
+		body       string
+		wantPath   string
+		wantDetail string
+	}{
+		{`{"name": 1}`, "name", "name: cannot unmarshal number into string"},
+		{`{"options": {"java_package": true}}`, "options.java_package", "options.java_package: cannot unmarshal bool into string"},
+		{`{"options": {"optimize_for": "FASTEST"}}`, "options.optimize_for", `options.optimize_for: unknown value "FASTEST"`},
+		{`{"dependency": ["a.proto", 1]}`, "dependency[1]", "dependency[1]: cannot unmarshal number into string"},
+		{`{"public_dependency": [1, "x"]}`, "public_dependency[1]", "public_dependency[1]: cannot unmarshal string into int32"},
+		{`{"messageType": [{"name": "A"}, {"field": [{"number": "one"}]}]}`, "message_type[1].field[0].number", "message_type[1].field[0].number: cannot unmarshal string into int32"},
+		{`{"options": 1}`, "options", "options: cannot unmarshal number into descriptorpb.FileOptions"},
+	}
+	for _, test := range tests {
+		err := DefaultUnmarshaler.Unmarshal(strings.NewReader(test.body), &descriptorpb.FileDescriptorProto{})
+		var fieldErr *jsonpb.FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Errorf("%s: Expect a *jsonpb.FieldError, Got: %v", test.body, err)
+			continue
+		}
+		if fieldErr.Path != test.wantPath || !strings.HasPrefix(err.Error(), test.wantDetail) {
+			t.Errorf("%s: Expect %s: %q, Got: %s: %q", test.body, test.wantPath, test.wantDetail, fieldErr.Path, err.Error())
+		}
+	}
+
+	rec := serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": "abc"}`))
+	var body DecodeErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expect a JSON body, Got: %s", rec.Body.String())
+	}
+	if rec.Code != http.StatusBadRequest || body.Field != "num_one" || body.Detail != `num_one: cannot unmarshal string "abc" into int64` {
+		t.Errorf("Expect a 400 for num_one, Got: %d %s", rec.Code, rec.Body.String())
+	}
+}
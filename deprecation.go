@@ -0,0 +1,45 @@
+package grpcj
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeprecatedEndpoint allows marking an endpoint as deprecated, e.g. the old path of a method during a URL migration.
+// Its responses carry a Deprecation header (RFC 9745) with the date the endpoint was deprecated and, when sunset is not zero,
+// a Sunset header (RFC 8594) with the date it will stop being served. The endpoint is the path without the PathPrefix,
+// either a default endpoint (e.g. "/CreateUser") or one added with AddEndpoints:
+//
+//	grpcj.AddEndpoints(map[string]interface{}{"/users:create": server.CreateUser})
+//	grpcj.DeprecatedEndpoint("/CreateUser", deprecatedAt, deprecatedAt.AddDate(0, 6, 0))
+func DeprecatedEndpoint(endpoint string, deprecated, sunset time.Time) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.deprecations == nil {
+			s.deprecations = map[string]deprecation{}
+		}
+		s.deprecations[endpoint] = deprecation{deprecated: deprecated, sunset: sunset}
+	}
+}
+
+// deprecation holds the dates of a deprecated endpoint.
+type deprecation struct {
+	deprecated time.Time
+	sunset     time.Time
+}
+
+// handler wraps the handler of a deprecated endpoint so that all its responses, including errors, carry the deprecation headers.
+func (d deprecation) handler(handler http.Handler) http.Handler {
+	deprecationHeader := "@" + strconv.FormatInt(d.deprecated.Unix(), 10)
+	var sunsetHeader string
+	if !d.sunset.IsZero() {
+		sunsetHeader = d.sunset.UTC().Format(http.TimeFormat)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecationHeader)
+		if sunsetHeader != "" {
+			w.Header().Set("Sunset", sunsetHeader)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
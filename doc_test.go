@@ -1,14 +1,21 @@
 package grpcj
 
 import (
+	"context"
 	"fmt"
+	"github.com/golang/protobuf/ptypes/empty"
 	"github.com/gorilla/handlers"
+	"net"
 	"net/http"
 	"time"
 )
 
 type grpcServer struct{}
 
+func (s *grpcServer) GenerateReport(ctx context.Context, req *empty.Empty) (*empty.Empty, error) {
+	return &empty.Empty{}, nil
+}
+
 func ExamplePort() {
 	Serve(&grpcServer{}, Port(":8080"))
 }
@@ -38,3 +45,40 @@ func ExampleServe() {
 	// With no options set, will default to port :8080 and request timeout of 30 seconds.
 	Serve(&grpcServer{})
 }
+
+func ExampleNewServer() {
+	serverHTTP, err := NewServer(&grpcServer{}, Port(":8080"))
+	if err != nil {
+		fmt.Println("Error creating server:", err)
+		return
+	}
+
+	// The caller owns the lifecycle of the server, e.g. serverHTTP.Shutdown(ctx) when the application exits.
+	go serverHTTP.ListenAndServe()
+}
+
+func ExampleListener() {
+	listener, err := net.Listen("unix", "/tmp/grpc-json.sock")
+	if err != nil {
+		fmt.Println("Error listening:", err)
+		return
+	}
+
+	// The Port option is ignored when a Listener is set.
+	Serve(&grpcServer{}, Listener(listener))
+}
+
+func ExampleMethodTimeout() {
+	server := &grpcServer{}
+
+	// All methods time out after 5 seconds, except for server.GenerateReport which may take up to 90 seconds.
+	Serve(server, Timeout(5*time.Second), MethodTimeout(server.GenerateReport, 90*time.Second))
+}
+
+func ExampleTLS() {
+	Serve(&grpcServer{}, Port(":8443"), TLS("/etc/ssl/server.crt", "/etc/ssl/server.key"))
+}
+
+func ExampleRequestID() {
+	Serve(&grpcServer{}, Middleware(RequestID()))
+}
@@ -0,0 +1,81 @@
+package grpcj
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// RejectDuplicateJSONKeys allows rejecting JSON request bodies with a 400 when an object has the same key more than once,
+// at any level of nesting. By default the last value of a duplicated key is used, which can let a request smuggle a value past a proxy
+// or a middleware that reads the first one. The body is parsed an extra time to find duplicates, so this is disabled by default.
+func RejectDuplicateJSONKeys(reject bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.rejectDuplicateKeys = reject
+	}
+}
+
+// duplicateKeyError is returned for a JSON object that has the same key more than once.
+type duplicateKeyError struct {
+	key string
+}
+
+func (e *duplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key %q in JSON object", e.key)
+}
+
+// readBodyWithoutDuplicateKeys reads the body of r and returns an error if it has a JSON object with duplicate keys.
+// Syntax errors are left to the unmarshaler, which reports them with more context.
+func readBodyWithoutDuplicateKeys(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var dupErr *duplicateKeyError
+	if err := checkDuplicateKeys(dec); errors.As(err, &dupErr) {
+		return nil, err
+	}
+	return body, nil
+}
+
+// checkDuplicateKeys reads the next JSON value of dec and returns a *duplicateKeyError if it has an object with duplicate keys.
+func checkDuplicateKeys(dec *json.Decoder) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		keys := map[string]bool{}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := tok.(string)
+			if keys[key] {
+				return &duplicateKeyError{key: key}
+			}
+			keys[key] = true
+			if err := checkDuplicateKeys(dec); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for dec.More() {
+			if err := checkDuplicateKeys(dec); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	// Consume the closing delimiter.
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,33 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectDuplicateJSONKeys(t *testing.T) {
+	tests := []struct {
+		body       string
+		reject     bool
+		wantStatus int
+	}{
+		{`{"num_one": 1, "num_two": 2}`, true, http.StatusOK},
+		{`{"num_one": 1, "num_two": 2, "num_one": 3}`, false, http.StatusOK},
+		{`{"num_one": 1, "num_two": 2, "num_one": 3}`, true, http.StatusBadRequest},
+		{`{"num_one": 1, "numOne": 3}`, true, http.StatusOK},
+		{`{"num_one": 1, "num_two": 2, "unknown": [{"a": 1}, {"a": 2, "a": 3}]}`, true, http.StatusBadRequest},
+		{`{"num_one": 1,`, true, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, RejectDuplicateJSONKeys(test.reject), AllowUnknownFields(true))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", test.body))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s (reject: %t): Expect status: %d, Got: %d, Body: %s", test.body, test.reject, test.wantStatus, rec.Code, rec.Body.String())
+		}
+	}
+}
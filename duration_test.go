@@ -0,0 +1,68 @@
+package grpcj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/zang-cloud/grpc-json/jsonpb"
+)
+
+type durationMessage struct {
+	D1 *duration.Duration `protobuf:"bytes,1,opt,name=d1,proto3" json:"d1,omitempty"`
+	D2 duration.Duration  `protobuf:"bytes,2,opt,name=d2,proto3" json:"d2"`
+}
+
+func (m *durationMessage) Reset()         { *m = durationMessage{} }
+func (m *durationMessage) String() string { return m.D1.String() + "," + m.D2.String() }
+func (*durationMessage) ProtoMessage()    {}
+
+func TestDurationMarshaling(t *testing.T) {
+	tests := []struct {
+		instance     *durationMessage
+		emitDefaults bool
+		expect       string
+	}{
+		{&durationMessage{}, true, `{"d1":null,"d2":"0.000s"}`},
+		{&durationMessage{}, false, `{"d2":"0.000s"}`},
+		{&durationMessage{D1: &duration.Duration{}}, true, `{"d1":"0.000s","d2":"0.000s"}`},
+		{&durationMessage{D1: &duration.Duration{}}, false, `{"d1":"0.000s","d2":"0.000s"}`},
+		{&durationMessage{D1: &duration.Duration{Seconds: 3, Nanos: 500000000}, D2: duration.Duration{Seconds: 1, Nanos: 1000}}, true, `{"d1":"3.500s","d2":"1.000001s"}`},
+		{&durationMessage{D1: &duration.Duration{Seconds: -1, Nanos: -500}, D2: duration.Duration{Nanos: -1}}, false, `{"d1":"-1.000000500s","d2":"-0.000000001s"}`},
+	}
+	for _, test := range tests {
+		marshaler := &jsonpb.Marshaler{EmitDefaults: test.emitDefaults}
+		res, err := marshaler.MarshalToString(test.instance)
+		if err != nil {
+			t.Errorf("%v: Error to Marshal the Structure, Error:%s", test.instance, err)
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("%v: Expect: %s, Got: %s", test.instance, test.expect, res)
+		}
+
+		var got durationMessage
+		if err := DefaultUnmarshaler.Unmarshal(strings.NewReader(res), &got); err != nil {
+			t.Errorf("%v: Error to Unmarshal %s, Error:%s", test.instance, res, err)
+			continue
+		}
+		if got.D1.GetSeconds() != test.instance.D1.GetSeconds() || got.D1.GetNanos() != test.instance.D1.GetNanos() ||
+			got.D2.Seconds != test.instance.D2.Seconds || got.D2.Nanos != test.instance.D2.Nanos {
+			t.Errorf("%s: Expect: %v, Got: %v", res, test.instance, &got)
+		}
+	}
+}
+
+func TestDurationErrors(t *testing.T) {
+	if _, err := DefaultMarshaler.MarshalToString(&durationMessage{D1: &duration.Duration{Seconds: 1, Nanos: -1}}); err == nil {
+		t.Error("Expect an error for a Duration with seconds and nanos of different signs")
+	}
+
+	var got durationMessage
+	if err := DefaultUnmarshaler.Unmarshal(strings.NewReader(`{"d1":{"seconds":3,"nanos":5}}`), &got); err != nil || got.D1.GetSeconds() != 3 || got.D1.GetNanos() != 5 {
+		t.Errorf("Expect the object form of a Duration to be accepted, Got: %v, Error:%v", &got, err)
+	}
+	if err := DefaultUnmarshaler.Unmarshal(strings.NewReader(`{"d1":"three seconds"}`), &got); err == nil {
+		t.Error("Expect an error for an invalid Duration string")
+	}
+}
@@ -0,0 +1,150 @@
+package grpcj
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codeNames maps gRPC codes to their canonical google.rpc.Code names.
+var codeNames = map[codes.Code]string{
+	codes.OK:                 "OK",
+	codes.Canceled:           "CANCELLED",
+	codes.Unknown:            "UNKNOWN",
+	codes.InvalidArgument:    "INVALID_ARGUMENT",
+	codes.DeadlineExceeded:   "DEADLINE_EXCEEDED",
+	codes.NotFound:           "NOT_FOUND",
+	codes.AlreadyExists:      "ALREADY_EXISTS",
+	codes.PermissionDenied:   "PERMISSION_DENIED",
+	codes.ResourceExhausted:  "RESOURCE_EXHAUSTED",
+	codes.FailedPrecondition: "FAILED_PRECONDITION",
+	codes.Aborted:            "ABORTED",
+	codes.OutOfRange:         "OUT_OF_RANGE",
+	codes.Unimplemented:      "UNIMPLEMENTED",
+	codes.Internal:           "INTERNAL",
+	codes.Unavailable:        "UNAVAILABLE",
+	codes.DataLoss:           "DATA_LOSS",
+	codes.Unauthenticated:    "UNAUTHENTICATED",
+}
+
+func codeName(code codes.Code) string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return codeNames[codes.Unknown]
+}
+
+// ErrorBody is the JSON body written for RPC errors when the JSONErrors option is enabled.
+type ErrorBody struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details []json.RawMessage `json:"details"`
+}
+
+// writeError writes err to the response. The HTTP status code is derived from the gRPC status of err or of an error it wraps,
+// errors that don't carry a gRPC status are treated as codes.Unknown and result in a 500.
+// Timeouts are always written as JSON, like with the JSONErrors option, so clients can tell them apart from gateway timeouts.
+// The ErrorHandler option takes over when it is set, ctx is the context passed to the method.
+func writeError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error, httpServerOpts *serverOpts) {
+	if httpServerOpts.errorHandler != nil {
+		httpServerOpts.errorHandler(ctx, w, r, err)
+		return
+	}
+	st := status.Convert(contextError(err))
+	if !httpServerOpts.jsonErrors && st.Code() != codes.DeadlineExceeded {
+		http.Error(w, err.Error(), runtime.HTTPStatusFromCode(st.Code()))
+		return
+	}
+	writeJSONStatus(w, st, httpServerOpts)
+}
+
+// ErrorHandler allows writing the responses to the errors returned by RPC methods, instead of the plain text or JSONErrors body,
+// e.g. to unwrap and classify errors that don't carry a gRPC status and shape the body according to an API error contract.
+// The handler is passed the context of the method, the request and the error as returned by the method and the UnaryInterceptors.
+// It owns the whole response and must write the status code. The headers set from the outgoing metadata of the method
+// (see MetadataToHeader) are already set on w. Errors reported before the method is called, such as malformed requests,
+// are not passed to the handler.
+func ErrorHandler(handler func(ctx context.Context, w http.ResponseWriter, r *http.Request, err error)) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.errorHandler = handler
+	}
+}
+
+// contextError returns the gRPC status error of the context errors returned by RPC methods (e.g. ctx.Err()),
+// which is DeadlineExceeded (504) when the request timed out and Canceled (408) when the client went away.
+func contextError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+// writeJSONStatus writes st as an ErrorBody, see statusBody.
+func writeJSONStatus(w http.ResponseWriter, st *status.Status, httpServerOpts *serverOpts) {
+	writeJSONError(w, statusBody(st, httpServerOpts), runtime.HTTPStatusFromCode(st.Code()), httpServerOpts.jsonErrorContentType())
+}
+
+// statusBody returns the ErrorBody of st, the details are marshaled with the configured marshaler.
+func statusBody(st *status.Status, httpServerOpts *serverOpts) ErrorBody {
+	body := ErrorBody{
+		Code:    codeName(st.Code()),
+		Message: st.Message(),
+		Details: []json.RawMessage{},
+	}
+	// The details are marshaled as google.protobuf.Any messages, with an @type field naming their type like in the JSON
+	// representation of google.rpc.Status, so clients can tell e.g. a google.rpc.BadRequest apart from other details.
+	for _, detail := range st.Proto().GetDetails() {
+		var buf bytes.Buffer
+		// Details of types that are not linked into the binary can't be marshaled, they are skipped.
+		if err := httpServerOpts.marshaler.Marshal(&buf, detail); err != nil {
+			continue
+		}
+		body.Details = append(body.Details, json.RawMessage(buf.Bytes()))
+	}
+	return body
+}
+
+func writeJSONError(w http.ResponseWriter, body ErrorBody, httpStatus int, contentType string) {
+	resp, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, "An error has occured", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpStatus)
+	w.Write(resp)
+}
+
+// NotFoundHandler allows answering requests to unknown paths with the given handler instead of the plain text 404 of http.ServeMux.
+// Pass nil to use a JSON 404 in the same format as JSONErrors:
+//
+//	{"code": "NOT_FOUND", "message": "no endpoint for /Unknown", "details": []}
+func NotFoundHandler(handler http.Handler) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if handler == nil {
+			handler = notFoundHandler(s)
+		}
+		s.notFoundHandler = handler
+	}
+}
+
+// notFoundHandler answers requests with a JSON 404 written with the JSON error content type, see ErrorContentType.
+func notFoundHandler(httpServerOpts *serverOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONError(w, ErrorBody{
+			Code:    codeName(codes.NotFound),
+			Message: "no endpoint for " + r.URL.Path,
+			Details: []json.RawMessage{},
+		}, http.StatusNotFound, httpServerOpts.jsonErrorContentType())
+	})
+}
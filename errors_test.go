@@ -0,0 +1,280 @@
+package grpcj
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPlainTextErrors(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{errors.New("boom"), http.StatusInternalServerError},
+		{status.Error(codes.NotFound, "no such thing"), http.StatusNotFound},
+		{status.Error(codes.InvalidArgument, "bad input"), http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		rec := serveMethod(&testServer{err: test.err}, "Add", newJSONRequest("POST", "/Add", `{}`))
+		if rec.Code != test.wantStatus {
+			t.Errorf("Expect status: %d, Got: %d", test.wantStatus, rec.Code)
+		}
+		if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+			t.Errorf("Expect plain text Content-Type, Got: %s", rec.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	st, err := status.New(codes.InvalidArgument, "num_one must be positive").WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "num_one", Description: "must be positive"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantDetails int
+	}{
+		{errors.New("boom"), http.StatusInternalServerError, "UNKNOWN", "boom", 0},
+		{status.Error(codes.NotFound, "no such thing"), http.StatusNotFound, "NOT_FOUND", "no such thing", 0},
+		{st.Err(), http.StatusBadRequest, "INVALID_ARGUMENT", "num_one must be positive", 1},
+	}
+	for _, test := range tests {
+		rec := serveMethod(&testServer{err: test.err}, "Add", newJSONRequest("POST", "/Add", `{}`), JSONErrors(true))
+		if rec.Code != test.wantStatus {
+			t.Errorf("Expect status: %d, Got: %d", test.wantStatus, rec.Code)
+		}
+		if rec.Header().Get("Content-Type") != defaultContentType {
+			t.Errorf("Expect Content-Type: %s, Got: %s", defaultContentType, rec.Header().Get("Content-Type"))
+		}
+		var body ErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Error to Unmarshal the error body %q, Error:%s", rec.Body.String(), err)
+		}
+		if body.Code != test.wantCode || body.Message != test.wantMessage || len(body.Details) != test.wantDetails {
+			t.Errorf("Expect: %s %q with %d details, Got: %s", test.wantCode, test.wantMessage, test.wantDetails, rec.Body.String())
+		}
+	}
+
+	rec := serveMethod(&testServer{err: st.Err()}, "Add", newJSONRequest("POST", "/Add", `{}`), JSONErrors(true))
+	for _, want := range []string{`"details":[{"@type":"type.googleapis.com/google.rpc.BadRequest"`, `{"field":"num_one","description":"must be positive"`} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("Expect the details with their type to contain %s, Got: %s", want, rec.Body.String())
+		}
+	}
+}
+
+func TestNotFoundHandler(t *testing.T) {
+	custom := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	tests := []struct {
+		options    []func(*serverOpts)
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{nil, "/Unknown", http.StatusNotFound, "404 page not found\n"},
+		{[]func(*serverOpts){NotFoundHandler(nil)}, "/Unknown", http.StatusNotFound, `{"code":"NOT_FOUND","message":"no endpoint for /Unknown","details":[]}`},
+		{[]func(*serverOpts){NotFoundHandler(nil)}, "/", http.StatusNotFound, `{"code":"NOT_FOUND","message":"no endpoint for /","details":[]}`},
+		{[]func(*serverOpts){NotFoundHandler(nil)}, "/Add", http.StatusOK, `{"sum":3}`},
+		{[]func(*serverOpts){NotFoundHandler(custom)}, "/Unknown", http.StatusTeapot, ""},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, `{"num_one": 1, "num_two": 2}`))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.path, test.wantStatus, rec.Code)
+		}
+		if rec.Body.String() != test.wantBody {
+			t.Errorf("%s: Expect body: %q, Got: %q", test.path, test.wantBody, rec.Body.String())
+		}
+	}
+}
+
+// truncatingMarshaler writes part of the response before failing.
+type truncatingMarshaler struct{}
+
+func (truncatingMarshaler) Marshal(w io.Writer, v interface{}) error {
+	w.Write([]byte(`{"sum":`))
+	return errors.New("can't marshal")
+}
+
+func TestMarshalError(t *testing.T) {
+	rec := serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`), Marshaler(truncatingMarshaler{}))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expect status: %d, Got: %d", http.StatusInternalServerError, rec.Code)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expect a JSON error body without the partial response, Got: %s", rec.Body.String())
+	}
+	if body.Code != "INTERNAL" {
+		t.Errorf("Expect code: INTERNAL, Got: %s", body.Code)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		options         []func(*serverOpts)
+		wantContentType string
+		wantErrorType   string
+	}{
+		{nil, defaultContentType, defaultContentType},
+		{[]func(*serverOpts){ContentType("application/vnd.myapp+json")}, "application/vnd.myapp+json", "application/vnd.myapp+json"},
+		{[]func(*serverOpts){ContentType("application/vnd.myapp+json"), ErrorContentType("application/problem+json")}, "application/vnd.myapp+json", "application/problem+json"},
+	}
+	for _, test := range tests {
+		rec := serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`), test.options...)
+		if got := rec.Header().Get("Content-Type"); got != test.wantContentType {
+			t.Errorf("Expect Content-Type: %s, Got: %s", test.wantContentType, got)
+		}
+		options := append(test.options, JSONErrors(true))
+		rec = serveMethod(&testServer{err: errors.New("boom")}, "Add", newJSONRequest("POST", "/Add", `{}`), options...)
+		if got := rec.Header().Get("Content-Type"); got != test.wantErrorType {
+			t.Errorf("Expect error Content-Type: %s, Got: %s", test.wantErrorType, got)
+		}
+		rec = serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": "invalid"}`), test.options...)
+		if got := rec.Header().Get("Content-Type"); rec.Code != http.StatusBadRequest || got != test.wantErrorType {
+			t.Errorf("Expect a 400 with the error Content-Type: %s, Got: %d %s", test.wantErrorType, rec.Code, got)
+		}
+	}
+}
+
+var errDatabase = errors.New("connection reset")
+
+func TestErrorHandler(t *testing.T) {
+	handler := ErrorHandler(func(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+		method, _ := MethodFromContext(ctx)
+		if errors.Is(err, errDatabase) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, `{"error":"retry later","method":%q,"path":%q}`, method, r.URL.Path)
+			return
+		}
+		w.WriteHeader(http.StatusTeapot)
+	})
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{fmt.Errorf("db: %w", errDatabase), http.StatusServiceUnavailable, `{"error":"retry later","method":"Add","path":"/Add"}`},
+		{status.Error(codes.NotFound, "no such thing"), http.StatusTeapot, ""},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{err: test.err}, handler, JSONErrors(true))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{}`))
+		if rec.Code != test.wantStatus || rec.Body.String() != test.wantBody {
+			t.Errorf("%v: Expect %d %s, Got: %d %s", test.err, test.wantStatus, test.wantBody, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Requests that can't be decoded don't reach the method.
+	rec := serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": "invalid"}`), handler)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expect a 400 for a malformed request, Got: %d", rec.Code)
+	}
+}
+
+type nilResponseServer struct{}
+
+func (nilResponseServer) Add(ctx context.Context, req *addRequest) (*addResponse, error) {
+	return nil, nil
+}
+
+func TestNilResponse(t *testing.T) {
+	nilInterceptor := UnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, nil
+	})
+	tests := []struct {
+		grpcServer interface{}
+		options    []func(*serverOpts)
+	}{
+		{nilResponseServer{}, nil},
+		{&testServer{}, []func(*serverOpts){nilInterceptor}},
+	}
+	for _, test := range tests {
+		options := append(test.options, JSONErrors(true))
+		rec := serveMethod(test.grpcServer, "Add", newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`), options...)
+		var body ErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Expect a JSON error body, Got: %s", rec.Body.String())
+		}
+		if rec.Code != http.StatusInternalServerError || body.Code != "INTERNAL" || body.Message != "method Add returned a nil response without an error" {
+			t.Errorf("Expect a 500 INTERNAL error, Got: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// accountError is a custom error type carrying a gRPC status.
+type accountError struct {
+	id string
+}
+
+func (e *accountError) Error() string { return "account " + e.id + " is locked" }
+
+func (e *accountError) GRPCStatus() *status.Status {
+	return status.New(codes.FailedPrecondition, e.Error())
+}
+
+// retryableError wraps an error through its Unwrap method.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return "retryable: " + e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+func TestWrappedStatusErrors(t *testing.T) {
+	tests := []struct {
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+	}{
+		{&accountError{id: "42"}, http.StatusBadRequest, "FAILED_PRECONDITION", "account 42 is locked"},
+		{fmt.Errorf("loading account: %w", &accountError{id: "42"}), http.StatusBadRequest, "FAILED_PRECONDITION", "loading account: account 42 is locked"},
+		{fmt.Errorf("handler: %w", retryableError{status.Error(codes.Unavailable, "db down")}), http.StatusServiceUnavailable, "UNAVAILABLE", "handler: retryable: rpc error: code = Unavailable desc = db down"},
+		{fmt.Errorf("loading account: %w", errors.New("boom")), http.StatusInternalServerError, "UNKNOWN", "loading account: boom"},
+	}
+	for _, test := range tests {
+		rec := serveMethod(&testServer{err: test.err}, "Add", newJSONRequest("POST", "/Add", `{}`), JSONErrors(true))
+		var body ErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Expect a JSON error body, Got: %s", rec.Body.String())
+		}
+		if rec.Code != test.wantStatus || body.Code != test.wantCode || body.Message != test.wantMessage {
+			t.Errorf("%v: Expect %d %s %q, Got: %d %s %q", test.err, test.wantStatus, test.wantCode, test.wantMessage, rec.Code, body.Code, body.Message)
+		}
+
+		rec = serveMethod(&testServer{err: test.err}, "Add", newJSONRequest("POST", "/Add", `{}`))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%v: Expect plain text status %d, Got: %d", test.err, test.wantStatus, rec.Code)
+		}
+	}
+}
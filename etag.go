@@ -0,0 +1,66 @@
+package grpcj
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// MethodETag enables conditional requests for a read method (e.g. MethodETag(server.GetAccount)). The method is matched by name like MethodTimeout.
+// Successful responses to GET requests get a strong ETag computed from the response body, and requests with an If-None-Match header
+// matching it are answered with a 304 Not Modified without a body. The method is still called to compute the response.
+func MethodETag(method interface{}) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.etagMethods == nil {
+			s.etagMethods = map[string]bool{}
+		}
+		methodName := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+		s.etagMethods[shortMethodName(methodName)] = true
+	}
+}
+
+// etag returns the strong entity tag of a response body.
+func etag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether the If-None-Match header of r matches the entity tag, using the weak comparison of RFC 9110.
+func etagMatches(r *http.Request, tag string) bool {
+	for _, header := range r.Header.Values("If-None-Match") {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeBody writes a successful response of methodName. When MethodETag applies, the ETag header is set
+// and a 304 without a body is written if the client already has the response. HEAD requests get the headers only.
+func writeBody(w http.ResponseWriter, r *http.Request, contentType string, body []byte, methodName string, httpServerOpts *serverOpts) {
+	code := httpServerOpts.successStatus(methodName)
+	if httpServerOpts.etagMethods[methodName] && (r.Method == http.MethodGet || r.Method == http.MethodHead) && code == http.StatusOK {
+		tag := etag(body)
+		w.Header().Set("ETag", tag)
+		if etagMatches(r, tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	if r.Method == http.MethodHead {
+		// The response to a HEAD request has the headers of the response to a GET request, including its length, but no body.
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(code)
+		return
+	}
+	w.WriteHeader(code)
+	w.Write(body)
+}
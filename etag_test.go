@@ -0,0 +1,51 @@
+package grpcj
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMethodETag(t *testing.T) {
+	server := &testServer{}
+	options := []func(*serverOpts){MethodETag(server.Add), AllowedHTTPMethods(map[string][]string{"/Add": {"GET", "POST"}})}
+
+	rec := serveMethod(server, "Add", newJSONRequest("GET", "/Add?num_one=1&num_two=2", ""), options...)
+	tag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || tag == "" {
+		t.Fatalf("Expect a 200 with an ETag, Got: %d %q", rec.Code, tag)
+	}
+
+	tests := []struct {
+		method      string
+		url         string
+		ifNoneMatch string
+		wantStatus  int
+		wantETag    bool
+	}{
+		{"GET", "/Add?num_one=1&num_two=2", tag, http.StatusNotModified, true},
+		{"GET", "/Add?num_one=1&num_two=2", `"other", W/` + tag, http.StatusNotModified, true},
+		{"GET", "/Add?num_one=1&num_two=2", "*", http.StatusNotModified, true},
+		{"GET", "/Add?num_one=2&num_two=2", tag, http.StatusOK, true},
+		{"GET", "/Add?num_one=1&num_two=2", `"other"`, http.StatusOK, true},
+		{"POST", "/Add", tag, http.StatusOK, false},
+	}
+	for _, test := range tests {
+		req := newJSONRequest(test.method, test.url, `{"num_one": 1, "num_two": 2}`)
+		req.Header.Set("If-None-Match", test.ifNoneMatch)
+		rec := serveMethod(server, "Add", req, options...)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s %s If-None-Match %s: Expect status: %d, Got: %d", test.method, test.url, test.ifNoneMatch, test.wantStatus, rec.Code)
+		}
+		if rec.Code == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("Expect no body with a 304, Got: %s", rec.Body.String())
+		}
+		if got := rec.Header().Get("ETag") != ""; got != test.wantETag {
+			t.Errorf("%s %s: Expect ETag: %t, Got: %q", test.method, test.url, test.wantETag, rec.Header().Get("ETag"))
+		}
+	}
+
+	rec = serveMethod(server, "Add", newJSONRequest("GET", "/Add?num_one=1", ""), AllowedHTTPMethods(map[string][]string{"/Add": {"GET"}}))
+	if rec.Header().Get("ETag") != "" {
+		t.Errorf("Expect no ETag without MethodETag, Got: %q", rec.Header().Get("ETag"))
+	}
+}
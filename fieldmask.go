@@ -0,0 +1,122 @@
+package grpcj
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldMaskParam is the query parameter listing the fields of the response to return, see EnableFieldMask.
+const fieldMaskParam = "fields"
+
+// EnableFieldMask allows clients to request a partial response with a fields query parameter listing the fields to return,
+// separated by commas (e.g. ?fields=id,name,address.city). Nested fields are selected with dotted paths, which go through repeated
+// and map fields of messages, and the field names can be the proto or the JSON names. The other fields of the response are cleared
+// before it is marshaled, so they are left out or written with their default value when EmitDefaults is set.
+// Unknown field paths are rejected with a 400 before the method is called. The fields parameter is not decoded into the request message.
+func EnableFieldMask() func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.fieldMask = true
+	}
+}
+
+// fieldMaskTree holds the selected fields of a message, a nil subtree selects the whole field.
+type fieldMaskTree map[protoreflect.Name]fieldMaskTree
+
+// parseFieldMask parses the comma-separated field paths of the fields parameter against the response message descriptor.
+func parseFieldMask(md protoreflect.MessageDescriptor, fields string) (fieldMaskTree, error) {
+	tree := fieldMaskTree{}
+	for _, path := range strings.Split(fields, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		node, fieldMD := tree, md
+		segments := strings.Split(path, ".")
+		for i, segment := range segments {
+			if fieldMD == nil {
+				return nil, fmt.Errorf("invalid field path %q: %s is not a message field", path, strings.Join(segments[:i], "."))
+			}
+			fd := fieldByName(fieldMD, segment)
+			if fd == nil {
+				return nil, fmt.Errorf("invalid field path %q: %s has no field %q", path, fieldMD.FullName(), segment)
+			}
+			last := i == len(segments)-1
+			subtree, ok := node[fd.Name()]
+			if ok && subtree == nil {
+				// A parent of the path is already selected as a whole.
+				break
+			}
+			if last {
+				node[fd.Name()] = nil
+				break
+			}
+			if !ok {
+				subtree = fieldMaskTree{}
+				node[fd.Name()] = subtree
+			}
+			node, fieldMD = subtree, fieldMessage(fd)
+		}
+	}
+	return tree, nil
+}
+
+// fieldMessage returns the descriptor of the message held by a field, or of the map values for map fields, or nil for other fields.
+func fieldMessage(fd protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+	if fd.IsMap() {
+		fd = fd.MapValue()
+	}
+	return fd.Message()
+}
+
+// prune clears the fields of m that are not selected by the tree.
+func (t fieldMaskTree) prune(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		subtree, ok := t[fd.Name()]
+		switch {
+		case !ok:
+			m.Clear(fd)
+		case subtree == nil:
+		case fd.IsList():
+			for i := 0; i < v.List().Len(); i++ {
+				subtree.prune(v.List().Get(i).Message())
+			}
+		case fd.IsMap():
+			v.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				subtree.prune(value.Message())
+				return true
+			})
+		default:
+			subtree.prune(v.Message())
+		}
+		return true
+	})
+}
+
+// takeFieldMask removes the fields parameter from the query of r, so it isn't decoded into the request message, and returns its value.
+func takeFieldMask(r *http.Request) (*http.Request, string) {
+	query := r.URL.Query()
+	fields, ok := query[fieldMaskParam]
+	if !ok {
+		return r, ""
+	}
+	query.Del(fieldMaskParam)
+	u := *r.URL
+	u.RawQuery = query.Encode()
+	r = r.WithContext(r.Context())
+	r.URL = &u
+	return r, strings.Join(fields, ",")
+}
+
+// applyFieldMask returns a copy of resp with only the fields selected by the tree, resp may be shared by the method and isn't modified.
+func applyFieldMask(resp proto.Message, tree fieldMaskTree) proto.Message {
+	if resp == nil || len(tree) == 0 {
+		return resp
+	}
+	resp = proto.Clone(resp)
+	tree.prune(proto.MessageReflect(resp))
+	return resp
+}
@@ -0,0 +1,72 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zang-cloud/grpc-json/jsonpb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type typeServer struct {
+	resp *typepb.Type
+	req  *addRequest
+}
+
+func (s *typeServer) Describe(ctx context.Context, req *addRequest) (*typepb.Type, error) {
+	s.req = req
+	return s.resp, nil
+}
+
+func TestFieldMask(t *testing.T) {
+	newServer := func() *typeServer {
+		return &typeServer{resp: &typepb.Type{
+			Name:          "Address",
+			Oneofs:        []string{"kind"},
+			SourceContext: &sourcecontextpb.SourceContext{FileName: "address.proto"},
+			Fields: []*typepb.Field{
+				{Name: "city", Number: 1, JsonName: "city"},
+				{Name: "zip_code", Number: 2, JsonName: "zipCode"},
+			},
+		}}
+	}
+	tests := []struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/Describe?fields=name", http.StatusOK, `{"name":"Address"}`},
+		{"/Describe?fields=name,sourceContext.fileName", http.StatusOK, `{"name":"Address","sourceContext":{"fileName":"address.proto"}}`},
+		{"/Describe?fields=fields.name&fields=source_context", http.StatusOK, `{"fields":[{"name":"city"},{"name":"zip_code"}],"sourceContext":{"fileName":"address.proto"}}`},
+		{"/Describe?fields=source_context,source_context.file_name", http.StatusOK, `{"sourceContext":{"fileName":"address.proto"}}`},
+		{"/Describe?fields=&num_one=1", http.StatusOK, `{"name":"Address","fields":[{"number":1,"name":"city","jsonName":"city"},{"number":2,"name":"zip_code","jsonName":"zipCode"}],"oneofs":["kind"],"sourceContext":{"fileName":"address.proto"}}`},
+		{"/Describe?fields=address", http.StatusBadRequest, ""},
+		{"/Describe?fields=name.first", http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		server := newServer()
+		rec := serveMethod(server, "Describe", newJSONRequest("GET", test.url, ""), EnableFieldMask(), Marshaler(&jsonpb.Marshaler{}), AllowedHTTPMethods(map[string][]string{"/Describe": {"GET"}}))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d %s", test.url, test.wantStatus, rec.Code, rec.Body.String())
+			continue
+		}
+		if test.wantBody != "" && rec.Body.String() != test.wantBody {
+			t.Errorf("%s: Expect: %s, Got: %s", test.url, test.wantBody, rec.Body.String())
+		}
+		if test.wantStatus == http.StatusOK && strings.Contains(test.url, "num_one=1") && server.req.NumOne != 1 {
+			t.Errorf("%s: Expect the other query parameters to be decoded, Got: %v", test.url, server.req)
+		}
+		if test.wantStatus == http.StatusOK && server.resp.Name != "Address" {
+			t.Errorf("%s: Expect the response of the method not to be modified", test.url)
+		}
+	}
+
+	// The fields parameter is an unknown field of the request message without EnableFieldMask.
+	rec := serveMethod(newServer(), "Describe", newJSONRequest("GET", "/Describe?fields=name", ""), AllowedHTTPMethods(map[string][]string{"/Describe": {"GET"}}))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expect status: %d, Got: %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,42 @@
+package grpcj
+
+import (
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/proto"
+	"github.com/zang-cloud/grpc-json/jsonpb"
+)
+
+// isGogoMessage reports whether msg was generated by gogo/protobuf. Generated gogo messages are registered with the gogo registry
+// and may use gogoproto extensions, such as stdtime time.Time fields, that the golang/protobuf runtime doesn't support.
+func isGogoMessage(msg interface{}) bool {
+	pb, ok := msg.(gogoproto.Message)
+	return ok && gogoproto.MessageName(pb) != ""
+}
+
+// marshalerFor returns the marshaler to write msg with: the MarshalerGOGO variant of a jsonpb Marshaler, with HandleStdTime set,
+// for gogo messages and marshaler unchanged otherwise.
+func marshalerFor(marshaler JSONPBMarshaler, msg interface{}) JSONPBMarshaler {
+	if m, ok := marshaler.(*jsonpb.Marshaler); ok && isGogoMessage(msg) {
+		gogo := jsonpb.MarshalerGOGO(*m)
+		gogo.HandleStdTime = true
+		return &gogo
+	}
+	return marshaler
+}
+
+// unmarshalerFor returns the unmarshaler to read msg with: the UnmarshalerGOGO variant of a jsonpb Unmarshaler for gogo messages
+// and unmarshaler unchanged otherwise.
+func unmarshalerFor(unmarshaler JSONPBUnmarshaler, msg interface{}) JSONPBUnmarshaler {
+	if u, ok := unmarshaler.(*jsonpb.Unmarshaler); ok && isGogoMessage(msg) {
+		return (*jsonpb.UnmarshalerGOGO)(u)
+	}
+	return unmarshaler
+}
+
+// marshalProtobuf encodes msg in the protobuf wire format, with the gogo/protobuf runtime for gogo messages.
+func marshalProtobuf(msg proto.Message) ([]byte, error) {
+	if isGogoMessage(msg) {
+		return gogoproto.Marshal(msg)
+	}
+	return proto.Marshal(msg)
+}
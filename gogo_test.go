@@ -0,0 +1,62 @@
+package grpcj
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+)
+
+// eventMessage is a message as generated by gogo/protobuf, with a stdtime field.
+type eventMessage struct {
+	Name    string    `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Created time.Time `protobuf:"bytes,2,opt,name=created,proto3,stdtime" json:"created"`
+}
+
+func (m *eventMessage) Reset()         { *m = eventMessage{} }
+func (m *eventMessage) String() string { return gogoproto.CompactTextString(m) }
+func (*eventMessage) ProtoMessage()    {}
+
+func init() {
+	gogoproto.RegisterType((*eventMessage)(nil), "grpcj.test.Event")
+}
+
+type eventServer struct{}
+
+func (eventServer) Postpone(ctx context.Context, req *eventMessage) (*eventMessage, error) {
+	return &eventMessage{Name: req.Name, Created: req.Created.Add(time.Hour)}, nil
+}
+
+func TestGogoMessages(t *testing.T) {
+	serverHTTP, err := NewServer(eventServer{})
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Postpone", `{"name":"launch","created":"2024-01-01T10:00:00Z"}`))
+	if want := `"created":"2024-01-01T11:00:00.000Z"`; rec.Code != 200 || !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("Expect 200 with %s, Got: %d %s", want, rec.Code, rec.Body.String())
+	}
+
+	body, err := gogoproto.Marshal(&eventMessage{Name: "launch", Created: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("Error to marshal the request, Error:%s", err)
+	}
+	req := httptest.NewRequest("POST", "/Postpone", bytes.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeProtobuf)
+	req.Header.Set("Accept", ContentTypeProtobuf)
+	rec = httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, req)
+	var resp eventMessage
+	if err := gogoproto.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Error to decode the response, Error:%s", err)
+	}
+	if want := time.Date(2024, time.January, 1, 11, 0, 0, 0, time.UTC); resp.Name != "launch" || !resp.Created.Equal(want) {
+		t.Errorf("Expect launch at %s, Got: %s at %s", want, resp.Name, resp.Created)
+	}
+}
@@ -0,0 +1,258 @@
+package grpcj
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthCheck allows defining an endpoint for healthchecks as well as a function to be executed at defined intervals to check the health of the service.
+// The healthcheck function will be run at the defined intervals and will respond to http requests with 200 or 500 depending on the status of the healthcheck,
+// along with a JSON body such as {"status":"HEALTHY"} or {"status":"UNHEALTHY"}.
+// Ideally this function should check any external dependencies such as pinging mysql etc. and should return any error.
+// The endpoint name must include the starting / (e.g. "/MyHealtchCheck"). The interval must be positive, building the server fails otherwise.
+//
+// HealthCheck can be passed multiple times to register several healthchecks, each with its own endpoint, function, interval and status.
+// For example a liveness endpoint that only reports that the process is up and a readiness endpoint that checks the dependencies:
+//
+//	grpcj.HealthCheck("/livez", func() error { return nil }, time.Minute)
+//	grpcj.HealthCheck("/readyz", pingDatabase, 10*time.Second)
+//
+// Liveness registers such a liveness endpoint without running a function in the background.
+// Use DependencyHealthCheck to report the status of each dependency separately, or StatusHealthCheck to report a degraded state.
+func HealthCheck(endpoint string, healthcheckFunc func() error, healthcheckInterval time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if healthcheckFunc == nil {
+			return
+		}
+		s.healthchecks = append(s.healthchecks, newHealthcheck(endpoint, healthcheckInterval, http.StatusOK, http.StatusInternalServerError, func() (HealthStatus, map[string]error, error) {
+			if err := healthcheckFunc(); err != nil {
+				return Unhealthy, nil, err
+			}
+			return Healthy, nil, nil
+		}))
+	}
+}
+
+// Liveness allows defining an endpoint that always responds with 200 and {"status":"HEALTHY"}, reporting that the process is up
+// and serving HTTP requests. Unlike HealthCheck, no function is run in the background. The endpoint name must include the starting /.
+// It can be combined with a HealthCheck for readiness:
+//
+//	grpcj.Liveness("/healthz")
+//	grpcj.HealthCheck("/readyz", pingDatabase, 10*time.Second)
+func Liveness(endpoint string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.livenessEndpoints = append(s.livenessEndpoints, endpoint)
+	}
+}
+
+// liveness serves the endpoints registered with Liveness.
+func liveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", defaultContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"HEALTHY"}`))
+}
+
+// DependencyHealthCheck is like HealthCheck but the function checks several named dependencies and returns the result of each one,
+// with a nil error for the dependencies that are healthy. The endpoint responds with 200 when all the dependencies are healthy
+// and 503 otherwise, the body reports the status of each dependency:
+//
+//	{"status":"DEGRADED","checks":{"mysql":"ok","redis":"fail"}}
+func DependencyHealthCheck(endpoint string, healthcheckFunc func() map[string]error, healthcheckInterval time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if healthcheckFunc == nil {
+			return
+		}
+		s.healthchecks = append(s.healthchecks, newHealthcheck(endpoint, healthcheckInterval, http.StatusServiceUnavailable, http.StatusServiceUnavailable, func() (HealthStatus, map[string]error, error) {
+			return Healthy, healthcheckFunc(), nil
+		}))
+	}
+}
+
+// HealthStatus is the state of the service reported by a StatusHealthCheck function.
+type HealthStatus int
+
+const (
+	// Healthy reports that the service works normally.
+	Healthy HealthStatus = iota
+	// Degraded reports that the service still serves requests with reduced functionality, e.g. when a cache is unreachable.
+	Degraded
+	// Unhealthy reports that the service can't serve requests.
+	Unhealthy
+)
+
+// String returns the status as written in the body of healthcheck endpoints, e.g. "DEGRADED".
+func (s HealthStatus) String() string {
+	switch s {
+	case Healthy:
+		return "HEALTHY"
+	case Degraded:
+		return "DEGRADED"
+	case Unhealthy:
+		return "UNHEALTHY"
+	}
+	return "HealthStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// StatusHealthCheck is like HealthCheck but the function returns the status of the service, so that a degraded service that still
+// serves requests can be told apart from one that is down. The endpoint responds with 200 for Healthy and Degraded and 503 for
+// Unhealthy by default, see HealthStatusCode. The error, if any, is logged along with the status.
+func StatusHealthCheck(endpoint string, healthcheckFunc func() (HealthStatus, error), healthcheckInterval time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if healthcheckFunc == nil {
+			return
+		}
+		s.healthchecks = append(s.healthchecks, newHealthcheck(endpoint, healthcheckInterval, http.StatusOK, http.StatusServiceUnavailable, func() (HealthStatus, map[string]error, error) {
+			status, err := healthcheckFunc()
+			return status, nil, err
+		}))
+	}
+}
+
+// HealthStatusCode allows setting the HTTP status code that all the healthcheck endpoints respond with for a status,
+// e.g. HealthStatusCode(grpcj.Unhealthy, http.StatusServiceUnavailable) for orchestrators that expect a 503 when a service is not ready.
+// A HealthCheck that fails is Unhealthy and a DependencyHealthCheck with a failing dependency is Degraded.
+// By default HealthCheck responds with 500 when Unhealthy, DependencyHealthCheck with 503 when Degraded,
+// and StatusHealthCheck as described there.
+func HealthStatusCode(status HealthStatus, code int) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.healthStatusCodes == nil {
+			s.healthStatusCodes = map[HealthStatus]int{}
+		}
+		s.healthStatusCodes[status] = code
+	}
+}
+
+// healthcheck holds the state of a single healthcheck endpoint.
+type healthcheck struct {
+	endpoint string
+	interval time.Duration
+	// check returns the status of the service, along with the result of each dependency for dependency healthchecks.
+	check func() (HealthStatus, map[string]error, error)
+	// statusCodes are the HTTP status codes of each HealthStatus.
+	statusCodes map[HealthStatus]int
+	// result holds the *healthcheckResult of the latest check, it is written by run and read by ServeHTTP concurrently.
+	result atomic.Value
+	logger LeveledLogger
+	// stop is closed when the server shuts down to end run.
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// healthcheckResult is the HTTP status and body reported by a healthcheck endpoint.
+type healthcheckResult struct {
+	status int
+	body   healthcheckBody
+}
+
+// healthcheckBody is the JSON body written by healthcheck endpoints.
+type healthcheckBody struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// healthUnknown is the status reported before the first check completes, see HealthCheckStartUnknown.
+const healthUnknown = "UNKNOWN"
+
+// newHealthcheck creates a healthcheck that responds with degradedStatus when Degraded and unhealthyStatus when Unhealthy.
+func newHealthcheck(endpoint string, interval time.Duration, degradedStatus, unhealthyStatus int, check func() (HealthStatus, map[string]error, error)) *healthcheck {
+	h := &healthcheck{
+		endpoint:    endpoint,
+		interval:    interval,
+		check:       check,
+		statusCodes: map[HealthStatus]int{Healthy: http.StatusOK, Degraded: degradedStatus, Unhealthy: unhealthyStatus},
+		logger:      defaultLogger(),
+		stop:        make(chan struct{}),
+	}
+	h.result.Store(&healthcheckResult{status: http.StatusOK, body: healthcheckBody{Status: Healthy.String()}})
+	return h
+}
+
+// statusCode returns the HTTP status code of status, unknown statuses are reported as Unhealthy.
+func (h *healthcheck) statusCode(status HealthStatus) int {
+	if code, ok := h.statusCodes[status]; ok {
+		return code
+	}
+	return h.statusCodes[Unhealthy]
+}
+
+// HealthCheckStartUnknown allows healthchecks to respond with a 503 and {"status":"UNKNOWN"} until their first check completes.
+// By default they report healthy until then. The first check runs as soon as the server is built, without waiting for the interval.
+func HealthCheckStartUnknown(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.healthStartUnknown = enabled
+	}
+}
+
+// run executes the healthcheck function right away and then at every interval, and records the resulting status.
+// It returns once the healthcheck is stopped.
+func (h *healthcheck) run() {
+	h.probe()
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			h.probe()
+		}
+	}
+}
+
+// close stops the healthcheck, the last result keeps being served.
+func (h *healthcheck) close() {
+	h.stopOnce.Do(func() { close(h.stop) })
+}
+
+// stopHealthchecks stops the healthchecks of the server, it is called when the server shuts down.
+func (s *serverOpts) stopHealthchecks() {
+	for _, check := range s.healthchecks {
+		check.close()
+	}
+}
+
+// probe executes the healthcheck function once, records the result and logs failures and recoveries.
+func (h *healthcheck) probe() {
+	status, results, err := h.check()
+	switch {
+	case err != nil && status == Unhealthy:
+		h.logger.Errorf("Healthcheck %s failed: %v", h.endpoint, err)
+	case err != nil:
+		h.logger.Errorf("Healthcheck %s is %s: %v", h.endpoint, status, err)
+	case status != Healthy:
+		h.logger.Errorf("Healthcheck %s is %s", h.endpoint, status)
+	}
+	var checks map[string]string
+	if results != nil {
+		checks = make(map[string]string, len(results))
+		for name, err := range results {
+			if err != nil {
+				h.logger.Errorf("Healthcheck %s of %s failed: %v", h.endpoint, name, err)
+				if status == Healthy {
+					status = Degraded
+				}
+				checks[name] = "fail"
+			} else {
+				checks[name] = "ok"
+			}
+		}
+	}
+	result := &healthcheckResult{status: h.statusCode(status), body: healthcheckBody{Status: status.String(), Checks: checks}}
+
+	previous := h.result.Swap(result).(*healthcheckResult)
+	if previous.body.Status != Healthy.String() && previous.body.Status != healthUnknown && status == Healthy {
+		h.logger.Infof("Healthcheck %s recovered", h.endpoint)
+	}
+}
+
+func (h *healthcheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	result := h.result.Load().(*healthcheckResult)
+	resp, _ := json.Marshal(result.body)
+	w.Header().Set("Content-Type", defaultContentType)
+	w.WriteHeader(result.status)
+	w.Write(resp)
+}
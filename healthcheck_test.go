@@ -0,0 +1,206 @@
+package grpcj
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestHealthChecks(t *testing.T) {
+	// Two servers in the same process must not share their healthcheck state.
+	var servers []*http.Server
+	for i := 0; i < 2; i++ {
+		serverHTTP, err := NewServer(&testServer{},
+			HealthCheck("/livez", func() error { return nil }, time.Millisecond),
+			HealthCheck("/readyz", func() error { return errors.New("database unreachable") }, time.Millisecond),
+			DependencyHealthCheck("/dependencies", func() map[string]error {
+				return map[string]error{"mysql": nil, "redis": errors.New("connection refused")}
+			}, time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		servers = append(servers, serverHTTP)
+	}
+
+	tests := []struct {
+		endpoint   string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/livez", http.StatusOK, `{"status":"HEALTHY"}`},
+		{"/readyz", http.StatusInternalServerError, `{"status":"UNHEALTHY"}`},
+		{"/dependencies", http.StatusServiceUnavailable, `{"status":"DEGRADED","checks":{"mysql":"ok","redis":"fail"}}`},
+	}
+	for _, serverHTTP := range servers {
+		for _, test := range tests {
+			var rec *httptest.ResponseRecorder
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				rec = httptest.NewRecorder()
+				serverHTTP.Handler.ServeHTTP(rec, httptest.NewRequest("GET", test.endpoint, nil))
+				if rec.Code == test.wantStatus {
+					break
+				}
+			}
+			if rec.Code != test.wantStatus {
+				t.Errorf("%s: Expect status: %d, Got: %d", test.endpoint, test.wantStatus, rec.Code)
+			}
+			if rec.Body.String() != test.wantBody {
+				t.Errorf("%s: Expect body: %s, Got: %s", test.endpoint, test.wantBody, rec.Body.String())
+			}
+			if contentType := rec.Header().Get("Content-Type"); contentType != defaultContentType {
+				t.Errorf("%s: Expect Content-Type: application/json, Got: %s", test.endpoint, contentType)
+			}
+		}
+	}
+}
+
+func TestHealthCheckFirstProbe(t *testing.T) {
+	release := make(chan struct{})
+	serverHTTP, err := NewServer(&testServer{},
+		HealthCheck("/readyz", func() error { return errors.New("database unreachable") }, time.Hour),
+		HealthCheck("/slowz", func() error { <-release; return nil }, time.Hour),
+		HealthCheckStartUnknown(true),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	get := func(endpoint string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, httptest.NewRequest("GET", endpoint, nil))
+		return rec
+	}
+
+	if rec := get("/slowz"); rec.Code != http.StatusServiceUnavailable || rec.Body.String() != `{"status":"UNKNOWN"}` {
+		t.Errorf("Expect a 503 UNKNOWN before the first check completes, Got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The first check runs right away, without waiting for the hour long interval.
+	tests := []struct {
+		endpoint   string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/readyz", http.StatusInternalServerError, `{"status":"UNHEALTHY"}`},
+		{"/slowz", http.StatusOK, `{"status":"HEALTHY"}`},
+	}
+	close(release)
+	for _, test := range tests {
+		var rec *httptest.ResponseRecorder
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if rec = get(test.endpoint); rec.Code == test.wantStatus {
+				break
+			}
+		}
+		if rec.Code != test.wantStatus || rec.Body.String() != test.wantBody {
+			t.Errorf("%s: Expect %d %s, Got: %d %s", test.endpoint, test.wantStatus, test.wantBody, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestHealthCheckStopsOnShutdown(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		serverHTTP, err := NewServer(&testServer{}, HealthCheck("/readyz", func() error { return nil }, time.Millisecond))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		if err := serverHTTP.Shutdown(context.Background()); err != nil {
+			t.Fatalf("Error to shut down the server, Error:%s", err)
+		}
+	}
+
+	var goroutines int
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if goroutines = runtime.NumGoroutine(); goroutines <= before {
+			break
+		}
+	}
+	if goroutines > before {
+		t.Errorf("Expect the healthcheck goroutines to stop, Got: %d goroutines, had %d", goroutines, before)
+	}
+}
+
+func TestHealthCheckInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := NewServer(&testServer{}, HealthCheck("/readyz", func() error { return nil }, interval)); err == nil {
+			t.Errorf("Expect an error for the interval %s", interval)
+		}
+	}
+}
+
+func TestStatusHealthCheck(t *testing.T) {
+	tests := []struct {
+		status     HealthStatus
+		options    []func(*serverOpts)
+		wantStatus int
+		wantBody   string
+	}{
+		{Healthy, nil, http.StatusOK, `{"status":"HEALTHY"}`},
+		{Degraded, nil, http.StatusOK, `{"status":"DEGRADED"}`},
+		{Unhealthy, nil, http.StatusServiceUnavailable, `{"status":"UNHEALTHY"}`},
+		{Degraded, []func(*serverOpts){HealthStatusCode(Degraded, http.StatusTooManyRequests)}, http.StatusTooManyRequests, `{"status":"DEGRADED"}`},
+		{HealthStatus(42), nil, http.StatusServiceUnavailable, `{"status":"HealthStatus(42)"}`},
+	}
+	for _, test := range tests {
+		status := test.status
+		httpServerOpts := applyOptions(append([]func(*serverOpts){
+			StatusHealthCheck("/readyz", func() (HealthStatus, error) { return status, errors.New("cache unreachable") }, time.Hour),
+		}, test.options...))
+		if _, err := newHandler([]interface{}{&testServer{}}, httpServerOpts); err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		check := httpServerOpts.healthchecks[0]
+		check.probe()
+		rec := httptest.NewRecorder()
+		check.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+		if rec.Code != test.wantStatus || rec.Body.String() != test.wantBody {
+			t.Errorf("%s: Expect %d %s, Got: %d %s", test.status, test.wantStatus, test.wantBody, rec.Code, rec.Body.String())
+		}
+		check.close()
+	}
+
+	// HealthStatusCode applies to the simple forms as well.
+	httpServerOpts := applyOptions([]func(*serverOpts){
+		HealthCheck("/readyz", func() error { return errors.New("database unreachable") }, time.Hour),
+		HealthStatusCode(Unhealthy, http.StatusServiceUnavailable),
+	})
+	if _, err := newHandler([]interface{}{&testServer{}}, httpServerOpts); err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	check := httpServerOpts.healthchecks[0]
+	defer check.close()
+	check.probe()
+	rec := httptest.NewRecorder()
+	check.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expect a 503 for a failing HealthCheck with HealthStatusCode, Got: %d", rec.Code)
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	before := runtime.NumGoroutine()
+	serverHTTP, err := NewServer(&testServer{}, Liveness("/healthz"), PathPrefix("/api"))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	if goroutines := runtime.NumGoroutine(); goroutines > before {
+		t.Errorf("Expect no healthcheck goroutine, Got: %d goroutines, had %d", goroutines, before)
+	}
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"status":"HEALTHY"}` {
+		t.Errorf("Expect 200 HEALTHY, Got: %d %s", rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != defaultContentType {
+		t.Errorf("Expect Content-Type: application/json, Got: %s", contentType)
+	}
+
+	if _, err := NewServer(&testServer{}, Liveness("/healthz"), HealthCheck("/healthz", func() error { return nil }, time.Hour)); err == nil {
+		t.Errorf("Expect an error for a liveness endpoint registered twice")
+	}
+}
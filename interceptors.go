@@ -0,0 +1,48 @@
+package grpcj
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// UnaryInterceptors allows running gRPC unary server interceptors around the unary RPC methods, so the interceptors installed
+// on the gRPC server for auth, logging or tracing also apply to the requests served over HTTP. The interceptors are called in order,
+// the first one is the outermost, after the request is decoded and validated. UnaryInterceptors can be passed multiple times.
+//
+// The grpc.UnaryServerInfo holds the server passed to Serve and the full method name, "/<service>/<method>" where the service is
+// the ServiceName of the ServiceDesc option (e.g. "/helloworld.Greeter/SayHello"), or "/<method>" when ServiceDesc is not set.
+// The request passed to the interceptors is a pointer to the request message.
+func UnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.unaryInterceptors = append(s.unaryInterceptors, interceptors...)
+	}
+}
+
+// fullMethodName returns the gRPC full method name of an RPC method of grpcServer, see UnaryInterceptors.
+func (s *serverOpts) fullMethodName(grpcServer interface{}, methodName string) string {
+	if serviceDesc := s.serviceDesc(grpcServer); serviceDesc != nil {
+		return "/" + serviceDesc.ServiceName + "/" + methodName
+	}
+	return "/" + methodName
+}
+
+// chainUnaryInterceptors returns an interceptor that calls the interceptors in order, or nil if there are none.
+func chainUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	if len(interceptors) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return interceptors[0](ctx, req, info, chainedUnaryHandler(interceptors, 0, info, handler))
+	}
+}
+
+// chainedUnaryHandler returns the handler passed to the interceptor at index i, which calls the next interceptor or the final handler.
+func chainedUnaryHandler(interceptors []grpc.UnaryServerInterceptor, i int, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) grpc.UnaryHandler {
+	if i == len(interceptors)-1 {
+		return handler
+	}
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		return interceptors[i+1](ctx, req, info, chainedUnaryHandler(interceptors, i+1, info, handler))
+	}
+}
@@ -0,0 +1,54 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryInterceptors(t *testing.T) {
+	server := &testServer{}
+	var calls []string
+	record := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name+" "+info.FullMethod)
+			if info.Server != server {
+				t.Errorf("Expect the interceptor to receive the server, Got: %v", info.Server)
+			}
+			return handler(ctx, req)
+		}
+	}
+	double := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		add := req.(*addRequest)
+		if add.NumOne < 0 {
+			return nil, status.Error(codes.PermissionDenied, "negative numbers are not allowed")
+		}
+		return handler(ctx, &addRequest{NumOne: add.NumOne * 2, NumTwo: add.NumTwo * 2})
+	}
+	serverHTTP, err := NewServer(server, ServiceDesc(&testServiceDesc), UnaryInterceptors(record("first"), record("second")), UnaryInterceptors(double))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`))
+	if got := strings.TrimSpace(rec.Body.String()); got != `{"sum":6}` {
+		t.Errorf("Expect the request replaced by the interceptor to be used, Got: %s", got)
+	}
+	wantCalls := []string{"first /test.TestService/Add", "second /test.TestService/Add"}
+	if strings.Join(calls, ",") != strings.Join(wantCalls, ",") {
+		t.Errorf("Expect the interceptors to be called in order: %v, Got: %v", wantCalls, calls)
+	}
+
+	rec = httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{"num_one": -1, "num_two": 2}`))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expect the error of the interceptor to be returned, Got: %d", rec.Code)
+	}
+}
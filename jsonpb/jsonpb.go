@@ -54,6 +54,8 @@ import (
 	"github.com/golang/protobuf/proto"
 
 	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/duration"
 	stpb "github.com/golang/protobuf/ptypes/struct"
 	"github.com/golang/protobuf/ptypes/timestamp"
 )
@@ -90,6 +92,82 @@ type Marshaler struct {
 
 	// Whether to try and marshal time.Time (needed for gogoproto).
 	HandleStdTime bool
+
+	// How to render Timestamp values, RFC 3339 with 3, 6 or 9 fractional digits by default.
+	TimestampFormat TimestampFormat
+}
+
+// TimestampFormat is the JSON representation of Timestamp values written by the Marshaler.
+// The Unmarshaler accepts all of them.
+type TimestampFormat int
+
+const (
+	// TimestampRFC3339Nano renders timestamps as RFC 3339 strings with 3, 6 or 9 fractional digits,
+	// depending on the required precision (e.g. "1986-03-10T05:05:05.000000005Z").
+	TimestampRFC3339Nano TimestampFormat = iota
+	// TimestampRFC3339 renders timestamps as RFC 3339 strings truncated to the second (e.g. "1986-03-10T05:05:05Z").
+	TimestampRFC3339
+	// TimestampUnixMillis renders timestamps as the number of milliseconds since the Unix epoch (e.g. 511074305000).
+	TimestampUnixMillis
+)
+
+// MarshalerOption modifies an option of a Marshaler, see Marshaler.With.
+type MarshalerOption func(*Marshaler)
+
+// With returns a copy of m with the given options applied, m itself is not modified.
+// This allows deriving per-request variants of a shared marshaler safely, e.g.
+//
+//	pretty := base.With(jsonpb.WithIndent("  "), jsonpb.WithInt64AsString(true))
+func (m Marshaler) With(opts ...MarshalerOption) Marshaler {
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
+}
+
+// With returns a copy of m with the given options applied, m itself is not modified.
+func (m MarshalerGOGO) With(opts ...MarshalerOption) MarshalerGOGO {
+	return MarshalerGOGO(Marshaler(m).With(opts...))
+}
+
+// WithEnumsAsInts sets whether enum values are rendered as integers.
+func WithEnumsAsInts(enabled bool) MarshalerOption {
+	return func(m *Marshaler) { m.EnumsAsInts = enabled }
+}
+
+// WithEmitDefaults sets whether fields with zero values are rendered.
+func WithEmitDefaults(enabled bool) MarshalerOption {
+	return func(m *Marshaler) { m.EmitDefaults = enabled }
+}
+
+// WithIndent sets the string to indent each level by, an empty string disables indentation.
+func WithIndent(indent string) MarshalerOption {
+	return func(m *Marshaler) { m.Indent = indent }
+}
+
+// WithOrigName sets whether fields are named after their original (.proto) names.
+func WithOrigName(enabled bool) MarshalerOption {
+	return func(m *Marshaler) { m.OrigName = enabled }
+}
+
+// WithInt64AsString sets whether int64 values are rendered as strings.
+func WithInt64AsString(enabled bool) MarshalerOption {
+	return func(m *Marshaler) { m.Int64AsString = enabled }
+}
+
+// WithUint64AsString sets whether uint64 values are rendered as strings.
+func WithUint64AsString(enabled bool) MarshalerOption {
+	return func(m *Marshaler) { m.Uint64AsString = enabled }
+}
+
+// WithTimestampFormat sets how Timestamp values are rendered.
+func WithTimestampFormat(format TimestampFormat) MarshalerOption {
+	return func(m *Marshaler) { m.TimestampFormat = format }
+}
+
+// WithAnyResolver sets the resolver of the type URLs of Any messages.
+func WithAnyResolver(resolver AnyResolver) MarshalerOption {
+	return func(m *Marshaler) { m.AnyResolver = resolver }
 }
 
 // AnyResolver takes a type URL, present in an Any message, and resolves it into
@@ -169,18 +247,10 @@ func (m *Marshaler) marshalObject(out *errWriter, vIn interface{}, indent, typeU
 	if !ok && m.HandleStdTime {
 		// The only non-protos we currently handle are time.Time since gogoproto supports it
 		if vTime, ok := vIn.(*time.Time); ok {
-			pTime, err := ptypes.TimestampProto(*vTime)
-			if err != nil {
-				return err
-			}
-			return m.marshalTimestamp(reflect.ValueOf(pTime).Elem(), out)
+			return m.marshalStdTime(*vTime, out)
 		}
 		if vTime, ok := vIn.(time.Time); ok {
-			pTime, err := ptypes.TimestampProto(vTime)
-			if err != nil {
-				return err
-			}
-			return m.marshalTimestamp(reflect.ValueOf(pTime).Elem(), out)
+			return m.marshalStdTime(vTime, out)
 		}
 	}
 
@@ -213,6 +283,12 @@ func (m *Marshaler) marshalObject(out *errWriter, vIn interface{}, indent, typeU
 	if ts, ok := s.Interface().(timestamp.Timestamp); ok {
 		return m.marshalEpochToStdFormat(ts.Seconds, int64(ts.Nanos), out)
 	}
+	if d, ok := v.(*duration.Duration); ok {
+		return m.marshalDuration(d.Seconds, int64(d.Nanos), out)
+	}
+	if a, ok := v.(*anypb.Any); ok {
+		return m.marshalAny(out, a.TypeUrl, a.Value, indent)
+	}
 
 	// Handle well-known types.
 	if wkt, ok := v.(wkt); ok {
@@ -225,19 +301,9 @@ func (m *Marshaler) marshalObject(out *errWriter, vIn interface{}, indent, typeU
 			return m.marshalValue(out, sprop.Prop[0], s.Field(0), indent)
 		case "Any":
 			// Any is a bit more involved.
-			return m.marshalAny(out, v, indent)
+			return m.marshalAny(out, s.Field(0).String(), s.Field(1).Bytes(), indent)
 		case "Duration":
-			// "Generated output always contains 3, 6, or 9 fractional digits,
-			//  depending on required precision."
-			s, ns := s.Field(0).Int(), s.Field(1).Int()
-			d := time.Duration(s)*time.Second + time.Duration(ns)*time.Nanosecond
-			x := fmt.Sprintf("%.9f", d.Seconds())
-			x = strings.TrimSuffix(x, "000")
-			x = strings.TrimSuffix(x, "000")
-			out.write(`"`)
-			out.write(x)
-			out.write(`s"`)
-			return out.err
+			return m.marshalDuration(s.Field(0).Int(), s.Field(1).Int(), out)
 		case "Struct", "ListValue":
 			// Let marshalValue handle the `Struct.fields` map or the `ListValue.values` slice.
 			// TODO: pass the correct Properties if needed.
@@ -389,8 +455,27 @@ func (m *Marshaler) marshalTimestamp(v reflect.Value, out *errWriter) error {
 	s, ns := v.Field(0).Int(), v.Field(1).Int()
 	return m.marshalEpochToStdFormat(s, ns, out)
 }
+
+// marshalStdTime writes a time.Time like a Timestamp, after checking it's in the range of a Timestamp.
+func (m *Marshaler) marshalStdTime(t time.Time, out *errWriter) error {
+	if _, err := ptypes.TimestampProto(t); err != nil {
+		return err
+	}
+	return m.marshalEpochToStdFormat(t.Unix(), int64(t.Nanosecond()), out)
+}
+
 func (m *Marshaler) marshalEpochToStdFormat(seconds int64, nanos int64, out *errWriter) error {
 	t := time.Unix(seconds, nanos).UTC()
+	switch m.TimestampFormat {
+	case TimestampUnixMillis:
+		out.write(strconv.FormatInt(t.UnixMilli(), 10))
+		return out.err
+	case TimestampRFC3339:
+		out.write(`"`)
+		out.write(t.Format(time.RFC3339))
+		out.write(`"`)
+		return out.err
+	}
 	// time.RFC3339Nano isn't exactly right (we need to get 3/6/9 fractional digits).
 	x := t.Format("2006-01-02T15:04:05.000000000")
 	x = strings.TrimSuffix(x, "000")
@@ -402,6 +487,35 @@ func (m *Marshaler) marshalEpochToStdFormat(seconds int64, nanos int64, out *err
 
 }
 
+// maxDurationSeconds is the range of Duration values, about 10,000 years.
+const maxDurationSeconds = 315576000000
+
+func (m *Marshaler) marshalDuration(seconds int64, nanos int64, out *errWriter) error {
+	if seconds < -maxDurationSeconds || seconds > maxDurationSeconds {
+		return fmt.Errorf("bad Duration: seconds out of range %d", seconds)
+	}
+	if nanos <= -1e9 || nanos >= 1e9 {
+		return fmt.Errorf("bad Duration: nanos out of range %d", nanos)
+	}
+	if (seconds > 0 && nanos < 0) || (seconds < 0 && nanos > 0) {
+		return fmt.Errorf("bad Duration: seconds %d and nanos %d have different signs", seconds, nanos)
+	}
+	// "Generated output always contains 3, 6, or 9 fractional digits,
+	//  depending on required precision."
+	// The seconds and nanos are formatted separately, converting to a float would lose precision on large durations.
+	sign := ""
+	if seconds < 0 || nanos < 0 {
+		sign, seconds, nanos = "-", -seconds, -nanos
+	}
+	x := fmt.Sprintf("%s%d.%09d", sign, seconds, nanos)
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, "000")
+	out.write(`"`)
+	out.write(x)
+	out.write(`s"`)
+	return out.err
+}
+
 func (m *Marshaler) writeSep(out *errWriter) {
 	if m.Indent != "" {
 		out.write(",\n")
@@ -410,15 +524,13 @@ func (m *Marshaler) writeSep(out *errWriter) {
 	}
 }
 
-func (m *Marshaler) marshalAny(out *errWriter, any proto.Message, indent string) error {
+// marshalAny writes an Any message given its type URL and serialized value.
+// The message type is resolved with the AnyResolver, or from the registered types when it's not set.
+func (m *Marshaler) marshalAny(out *errWriter, turl string, val []byte, indent string) error {
 	// "If the Any contains a value that has a special JSON mapping,
 	//  it will be converted as follows: {"@type": xxx, "value": yyy}.
 	//  Otherwise, the value will be converted into a JSON object,
 	//  and the "@type" field will be inserted to indicate the actual data type."
-	v := reflect.ValueOf(any).Elem()
-	turl := v.Field(0).String()
-	val := v.Field(1).Bytes()
-
 	var msg proto.Message
 	var err error
 	if m.AnyResolver != nil {
@@ -434,7 +546,7 @@ func (m *Marshaler) marshalAny(out *errWriter, any proto.Message, indent string)
 		return err
 	}
 
-	if _, ok := msg.(wkt); ok {
+	if isWellKnownType(msg) {
 		out.write("{")
 		if m.Indent != "" {
 			out.write("\n")
@@ -464,6 +576,22 @@ func (m *Marshaler) marshalAny(out *errWriter, any proto.Message, indent string)
 	return m.marshalObject(out, msg, indent, turl)
 }
 
+// isWellKnownType reports whether msg is a well-known type with a special JSON mapping,
+// which is wrapped in a "value" field when embedded in an Any.
+func isWellKnownType(msg proto.Message) bool {
+	if _, ok := msg.(wkt); ok {
+		return true
+	}
+	switch proto.MessageReflect(msg).Descriptor().FullName() {
+	case "google.protobuf.Any", "google.protobuf.Duration", "google.protobuf.Timestamp",
+		"google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue", "google.protobuf.FieldMask",
+		"google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return true
+	}
+	return false
+}
+
 func (m *Marshaler) marshalTypeURL(out *errWriter, indent, typeURL string) error {
 	if m.Indent != "" {
 		out.write(indent)
@@ -674,6 +802,50 @@ type Unmarshaler struct {
 	AnyResolver AnyResolver
 }
 
+// UnknownFieldError is returned by the Unmarshaler when the input has a field
+// that is not in the message and AllowUnknownFields is false.
+type UnknownFieldError struct {
+	// Field is the name of the field in the input.
+	Field string
+	// Message is the Go type of the message.
+	Message string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q in %v", e.Field, e.Message)
+}
+
+// FieldError is returned by the Unmarshaler for an invalid value of a field.
+type FieldError struct {
+	// Path is the path of the field in the input, e.g. "user.emails[1]".
+	// Fields are named after their original (.proto) names.
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	if typeErr, ok := e.Err.(*json.UnmarshalTypeError); ok {
+		return fmt.Sprintf("%s: cannot unmarshal %s into %v", e.Path, typeErr.Value, typeErr.Type)
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError prepends element, the name of a field or an index such as "[1]", to the path of err.
+func fieldError(element string, err error) error {
+	if fieldErr, ok := err.(*FieldError); ok {
+		path := fieldErr.Path
+		if !strings.HasPrefix(path, "[") {
+			path = "." + path
+		}
+		return &FieldError{Path: element + path, Err: fieldErr.Err}
+	}
+	return &FieldError{Path: element, Err: err}
+}
+
 // UnmarshalNext unmarshals the next protocol buffer from a JSON object stream.
 // This function is lenient and will decode any options permutations of the
 // related Marshaler.
@@ -718,6 +890,100 @@ func UnmarshalString(str string, pb proto.Message) error {
 	return new(Unmarshaler).Unmarshal(strings.NewReader(str), pb)
 }
 
+// unmarshalAny parses the JSON representation of an Any message into its type URL and serialized value.
+// The message type is resolved with the AnyResolver, or from the registered types when it's not set.
+func (u *Unmarshaler) unmarshalAny(inputValue json.RawMessage) (string, []byte, error) {
+	// Use json.RawMessage pointer type instead of value to support pre-1.8 version.
+	// 1.8 changed RawMessage.MarshalJSON from pointer type to value type, see
+	// https://github.com/golang/go/issues/14493
+	var jsonFields map[string]*json.RawMessage
+	if err := json.Unmarshal(inputValue, &jsonFields); err != nil {
+		return "", nil, err
+	}
+
+	val, ok := jsonFields["@type"]
+	if !ok || val == nil {
+		return "", nil, errors.New("Any JSON doesn't have '@type'")
+	}
+
+	var turl string
+	if err := json.Unmarshal([]byte(*val), &turl); err != nil {
+		return "", nil, fmt.Errorf("can't unmarshal Any's '@type': %q", *val)
+	}
+
+	var m proto.Message
+	var err error
+	if u.AnyResolver != nil {
+		m, err = u.AnyResolver.Resolve(turl)
+	} else {
+		m, err = defaultResolveAny(turl)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if isWellKnownType(m) {
+		val, ok := jsonFields["value"]
+		if !ok {
+			return "", nil, errors.New("Any JSON doesn't have 'value'")
+		}
+
+		if err := u.unmarshalValue(reflect.ValueOf(m).Elem(), *val, nil); err != nil {
+			return "", nil, fmt.Errorf("can't unmarshal Any nested proto %T: %v", m, err)
+		}
+	} else {
+		delete(jsonFields, "@type")
+		nestedProto, err := json.Marshal(jsonFields)
+		if err != nil {
+			return "", nil, fmt.Errorf("can't generate JSON for Any's nested proto to be unmarshaled: %v", err)
+		}
+
+		if err = u.unmarshalValue(reflect.ValueOf(m).Elem(), nestedProto, nil); err != nil {
+			return "", nil, fmt.Errorf("can't unmarshal Any nested proto %T: %v", m, err)
+		}
+	}
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return "", nil, fmt.Errorf("can't marshal proto %T into Any.Value: %v", m, err)
+	}
+	return turl, b, nil
+}
+
+// parseDuration parses a Duration string such as "3.5s" into its seconds and nanos, which have the same sign.
+func parseDuration(inputValue json.RawMessage) (int64, int32, error) {
+	unq, err := strconv.Unquote(string(inputValue))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	d, err := time.ParseDuration(unq)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad Duration: %v", err)
+	}
+
+	ns := d.Nanoseconds()
+	return ns / 1e9, int32(ns % 1e9), nil
+}
+
+// parseTimestamp parses a Timestamp written in any of the TimestampFormat representations:
+// an RFC 3339 string or a number of milliseconds since the Unix epoch.
+func parseTimestamp(inputValue json.RawMessage) (time.Time, error) {
+	if millis, err := strconv.ParseInt(string(inputValue), 10, 64); err == nil {
+		return time.UnixMilli(millis).UTC(), nil
+	}
+	unq, err := strconv.Unquote(string(inputValue))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, unq)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad Timestamp: %v", err)
+	}
+	return t, nil
+}
+
 // unmarshalValue converts/copies a value into the target.
 // prop may be nil.
 func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMessage, prop *proto.Properties) error {
@@ -740,15 +1006,20 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 		return jsu.UnmarshalJSONPB(u, []byte(inputValue))
 	}
 
-	if _, v1 := target.Interface().(timestamp.Timestamp); v1 {
-		unq, err := strconv.Unquote(string(inputValue))
+	// time.Time fields come from the gogoproto stdtime option and are written like Timestamps.
+	if t, ok := target.Addr().Interface().(*time.Time); ok {
+		parsed, err := parseTimestamp(inputValue)
 		if err != nil {
 			return err
 		}
+		*t = parsed
+		return nil
+	}
 
-		t, err := time.Parse(time.RFC3339Nano, unq)
+	if _, v1 := target.Interface().(timestamp.Timestamp); v1 {
+		t, err := parseTimestamp(inputValue)
 		if err != nil {
-			return fmt.Errorf("bad Timestamp: %v", err)
+			return err
 		}
 		unix := t.Unix()
 		nanoSecond := int64(t.Nanosecond())
@@ -757,6 +1028,26 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 		return nil
 	}
 
+	if a, ok := target.Addr().Interface().(*anypb.Any); ok {
+		turl, value, err := u.unmarshalAny(inputValue)
+		if err != nil {
+			return err
+		}
+		a.TypeUrl, a.Value = turl, value
+		return nil
+	}
+
+	// Durations are strings such as "3.5s", the {"seconds":3,"nanos":500000000} object is still accepted
+	// as it was written by previous versions of the Marshaler.
+	if d, ok := target.Addr().Interface().(*duration.Duration); ok && !bytes.HasPrefix(bytes.TrimSpace(inputValue), []byte("{")) {
+		seconds, nanos, err := parseDuration(inputValue)
+		if err != nil {
+			return err
+		}
+		d.Seconds, d.Nanos = seconds, nanos
+		return nil
+	}
+
 	// Handle well-known types that are not pointers.
 	if w, ok := target.Addr().Interface().(wkt); ok {
 		switch w.XXX_WellKnownType() {
@@ -764,92 +1055,27 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 			"Int32Value", "UInt32Value", "BoolValue", "StringValue", "BytesValue":
 			return u.unmarshalValue(target.Field(0), inputValue, prop)
 		case "Any":
-			// Use json.RawMessage pointer type instead of value to support pre-1.8 version.
-			// 1.8 changed RawMessage.MarshalJSON from pointer type to value type, see
-			// https://github.com/golang/go/issues/14493
-			var jsonFields map[string]*json.RawMessage
-			if err := json.Unmarshal(inputValue, &jsonFields); err != nil {
-				return err
-			}
-
-			val, ok := jsonFields["@type"]
-			if !ok || val == nil {
-				return errors.New("Any JSON doesn't have '@type'")
-			}
-
-			var turl string
-			if err := json.Unmarshal([]byte(*val), &turl); err != nil {
-				return fmt.Errorf("can't unmarshal Any's '@type': %q", *val)
-			}
-			target.Field(0).SetString(turl)
-
-			var m proto.Message
-			var err error
-			if u.AnyResolver != nil {
-				m, err = u.AnyResolver.Resolve(turl)
-			} else {
-				m, err = defaultResolveAny(turl)
-			}
+			turl, value, err := u.unmarshalAny(inputValue)
 			if err != nil {
 				return err
 			}
-
-			if _, ok := m.(wkt); ok {
-				val, ok := jsonFields["value"]
-				if !ok {
-					return errors.New("Any JSON doesn't have 'value'")
-				}
-
-				if err := u.unmarshalValue(reflect.ValueOf(m).Elem(), *val, nil); err != nil {
-					return fmt.Errorf("can't unmarshal Any nested proto %T: %v", m, err)
-				}
-			} else {
-				delete(jsonFields, "@type")
-				nestedProto, err := json.Marshal(jsonFields)
-				if err != nil {
-					return fmt.Errorf("can't generate JSON for Any's nested proto to be unmarshaled: %v", err)
-				}
-
-				if err = u.unmarshalValue(reflect.ValueOf(m).Elem(), nestedProto, nil); err != nil {
-					return fmt.Errorf("can't unmarshal Any nested proto %T: %v", m, err)
-				}
-			}
-
-			b, err := proto.Marshal(m)
-			if err != nil {
-				return fmt.Errorf("can't marshal proto %T into Any.Value: %v", m, err)
-			}
-			target.Field(1).SetBytes(b)
-
+			target.Field(0).SetString(turl)
+			target.Field(1).SetBytes(value)
 			return nil
 		case "Duration":
-			unq, err := strconv.Unquote(string(inputValue))
+			s, ns, err := parseDuration(inputValue)
 			if err != nil {
 				return err
 			}
-
-			d, err := time.ParseDuration(unq)
-			if err != nil {
-				return fmt.Errorf("bad Duration: %v", err)
-			}
-
-			ns := d.Nanoseconds()
-			s := ns / 1e9
-			ns %= 1e9
 			target.Field(0).SetInt(s)
-			target.Field(1).SetInt(ns)
+			target.Field(1).SetInt(int64(ns))
 			return nil
 		case "Timestamp":
-			unq, err := strconv.Unquote(string(inputValue))
+			t, err := parseTimestamp(inputValue)
 			if err != nil {
 				return err
 			}
 
-			t, err := time.Parse(time.RFC3339Nano, unq)
-			if err != nil {
-				return fmt.Errorf("bad Timestamp: %v", err)
-			}
-
 			target.Field(0).SetInt(t.Unix())
 			target.Field(1).SetInt(int64(t.Nanosecond()))
 			return nil
@@ -931,6 +1157,10 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 	if targetType.Kind() == reflect.Struct {
 		var jsonFields map[string]json.RawMessage
 		if err := json.Unmarshal(inputValue, &jsonFields); err != nil {
+			if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+				// Report the message type rather than the intermediate map.
+				return &json.UnmarshalTypeError{Value: typeErr.Value, Type: targetType}
+			}
 			return err
 		}
 
@@ -969,20 +1199,27 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 			}
 
 			if err := u.unmarshalValue(target.Field(i), valueForField, sprops.Prop[i]); err != nil {
-				return err
+				return fieldError(sprops.Prop[i].OrigName, err)
 			}
 		}
 		// Check for any oneof fields.
 		if len(jsonFields) > 0 {
+			// At most one field of each oneof can be set, the oneof fields are keyed by the index of their struct field.
+			setOneofs := make(map[int]string)
 			for _, oop := range sprops.OneofTypes {
 				raw, ok := consumeField(oop.Prop)
 				if !ok {
 					continue
 				}
+				if other, ok := setOneofs[oop.Field]; ok {
+					oneof := target.Type().Field(oop.Field).Tag.Get("protobuf_oneof")
+					return fmt.Errorf("oneof %s can only have one of its fields set, got %s and %s", oneof, other, oop.Prop.OrigName)
+				}
+				setOneofs[oop.Field] = oop.Prop.OrigName
 				nv := reflect.New(oop.Type.Elem())
 				target.Field(oop.Field).Set(nv)
 				if err := u.unmarshalValue(nv.Elem().Field(0), raw, oop.Prop); err != nil {
-					return err
+					return fieldError(oop.Prop.OrigName, err)
 				}
 			}
 		}
@@ -1013,7 +1250,7 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 				f = fname
 				break
 			}
-			return fmt.Errorf("unknown field %q in %v", f, targetType)
+			return &UnknownFieldError{Field: f, Message: targetType.String()}
 		}
 		return nil
 	}
@@ -1029,7 +1266,7 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 			target.Set(reflect.MakeSlice(targetType, l, l))
 			for i := 0; i < l; i++ {
 				if err := u.unmarshalValue(target.Index(i), slc[i], prop); err != nil {
-					return err
+					return fieldError(fmt.Sprintf("[%d]", i), err)
 				}
 			}
 		}
@@ -1067,7 +1304,7 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 				// Unmarshal map value.
 				v := reflect.New(targetType.Elem()).Elem()
 				if err := u.unmarshalValue(v, raw, valprop); err != nil {
-					return err
+					return fieldError("["+ks+"]", err)
 				}
 				target.SetMapIndex(k, v)
 			}
@@ -1079,7 +1316,11 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue json.RawMe
 	// the quotes and proceed as normal.
 	isNum := targetType.Kind() == reflect.Int64 || targetType.Kind() == reflect.Uint64
 	if isNum && strings.HasPrefix(string(inputValue), `"`) {
-		inputValue = inputValue[1 : len(inputValue)-1]
+		if err := json.Unmarshal(inputValue[1:len(inputValue)-1], target.Addr().Interface()); err != nil {
+			// The string doesn't hold a number, which is a type mismatch rather than a syntax error of the input.
+			return &json.UnmarshalTypeError{Value: "string " + string(inputValue), Type: targetType}
+		}
+		return nil
 	}
 
 	// Non-finite numbers can be encoded as strings.
@@ -0,0 +1,50 @@
+package grpcj
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// LeveledLogger is the logger used for the messages of the server: its startup and shutdown, healthcheck failures,
+// recovered panics and marshal errors. *logrus.Logger and *logrus.Entry implement it.
+type LeveledLogger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Logger allows routing the messages of the server to the application's logger instead of the global logrus logger, which is the default.
+// The AccessLog middleware takes its own logger.
+func Logger(logger LeveledLogger) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.logger = logger
+	}
+}
+
+// Quiet disables the informational messages about the lifecycle of the server, such as receiving a shutdown signal
+// and completing the graceful shutdown. Errors are still logged, and healthcheck logging is not affected.
+func Quiet() func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.quiet = true
+	}
+}
+
+// lifecycleLogger returns the logger of the messages about the lifecycle of the server, see Quiet.
+func (s *serverOpts) lifecycleLogger() LeveledLogger {
+	if s.quiet {
+		return quietLogger{s.logger}
+	}
+	return s.logger
+}
+
+// quietLogger discards the debug and informational messages of a logger.
+type quietLogger struct {
+	LeveledLogger
+}
+
+func (quietLogger) Debugf(format string, args ...interface{}) {}
+func (quietLogger) Infof(format string, args ...interface{})  {}
+
+// defaultLogger is the logger used when the Logger option is not set.
+func defaultLogger() LeveledLogger {
+	return logrus.StandardLogger()
+}
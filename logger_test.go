@@ -0,0 +1,82 @@
+package grpcj
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger records the messages logged at each level.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+": "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+func (l *recordingLogger) Infof(format string, args ...interface{})  { l.log("info", format, args...) }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) { l.log("error", format, args...) }
+
+func (l *recordingLogger) contains(prefix string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, message := range l.messages {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	httpServerOpts := applyOptions([]func(*serverOpts){
+		HealthCheck("/health", func() error { return errors.New("db down") }, time.Hour),
+		Logger(logger),
+	})
+	serverHTTP, err := newServer([]interface{}{&panicServer{}}, httpServerOpts)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), newJSONRequest("POST", "/Add", `{}`))
+	if !logger.contains("error: Recovered from panic serving /Add") {
+		t.Errorf("Expect the recovered panic to be logged, Got: %q", logger.messages)
+	}
+
+	httpServerOpts.healthchecks[0].probe()
+	if !logger.contains("error: Healthcheck /health failed: db down") {
+		t.Errorf("Expect the healthcheck failure to be logged, Got: %q", logger.messages)
+	}
+
+	serverHTTP, err = NewServer(&testServer{})
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	shutdown(serverHTTP, time.Second, logger)
+	if !logger.contains("info: All requests completed") {
+		t.Errorf("Expect the shutdown to be logged, Got: %q", logger.messages)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	logger := &recordingLogger{}
+	serverHTTP, err := NewServer(&testServer{})
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	lifecycleLogger := applyOptions([]func(*serverOpts){Logger(logger), Quiet()}).lifecycleLogger()
+	shutdown(serverHTTP, time.Second, lifecycleLogger)
+	lifecycleLogger.Errorf("Error gracefully shutting down grpc-json server: %v", "boom")
+	if len(logger.messages) != 1 || !logger.contains("error: Error gracefully shutting down") {
+		t.Errorf("Expect only the error to be logged, Got: %q", logger.messages)
+	}
+}
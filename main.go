@@ -3,25 +3,50 @@ package grpcj
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/joncalhoun/qson"
-	"github.com/sirupsen/logrus"
+	gwruntime "github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/zang-cloud/grpc-json/jsonpb"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
 	defaultPort    = ":8080"
 	defaultTimeout = 30 * time.Second
+
+	defaultSSEHeartbeat = 15 * time.Second
+
+	defaultMaxRequestBodySize = 4 << 20
+
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = time.Minute
+	defaultIdleTimeout       = 2 * time.Minute
+
+	// defaultContentType declares the charset so clients don't fall back to ISO-8859-1, the marshalers always write UTF-8.
+	defaultContentType = contentTypeJSON + "; charset=utf-8"
 )
 
 var DefaultMarshaler = &jsonpb.Marshaler{EnumsAsInts: true, EmitDefaults: true, OrigName: true, Int64AsString: false, Uint64AsString: false}
@@ -43,14 +68,79 @@ type serverOpts struct {
 	timeout             time.Duration
 	marshaler           JSONPBMarshaler
 	unmarshaler         JSONPBUnmarshaler
+	runtimeMarshaler    gwruntime.Marshaler
 	endpointToMethodMap map[string]interface{}
+	overrideDefaults    bool
+	autoRegistration    bool
+	deprecations        map[string]deprecation
 	allowedMethods      []string
+	disallowedCode      codes.Code
 	middlewareHandlers  []MiddlewareFunc
-	healthcheckEndpoint string
-	healthcheckFunc     func() error
-	healthcheckInterval time.Duration
+	healthchecks        []*healthcheck
+	livenessEndpoints   []string
+	healthStartUnknown  bool
+	healthStatusCodes   map[HealthStatus]int
+	jsonErrors          bool
+	errorHandler        func(context.Context, http.ResponseWriter, *http.Request, error)
+	httpMethods         map[string][]string
+	listener            net.Listener
+	headerToMetadata    func(http.Header) metadata.MD
+	metadataToHeader    func(key string) (string, bool)
+	serviceDescs        []*grpc.ServiceDesc
+	sseHeartbeat        time.Duration
+	webSocket           bool
+	methodTimeouts      map[string]time.Duration
+	successStatuses     map[string]int
+	emptyNoContent      bool
+	fieldMask           bool
+	poolRequests        bool
+	trustedProxies      []*net.IPNet
+	trustedProxyCount   int
+	trustedProxiesErr   error
+	etagMethods         map[string]bool
+	cacheTTLs           map[string]time.Duration
+	responseCacheSize   int
+	responseCache       *responseCache
+	tlsCertFile         string
+	tlsKeyFile          string
+	tlsConfig           *tls.Config
+	recoverPanics       bool
+	compression         bool
+	compressionLevel    int
+	compressionMinSize  int
+	maxRequestBodySize  int64
+	metricsRegisterer   prometheus.Registerer
+	metricsEndpoint     string
+	metrics             *metrics
+	meterProvider       metric.MeterProvider
+	otelMetrics         *otelMetrics
+	tracer              trace.Tracer
+	pathPrefix          string
+	endpointNamer       func(methodName string) string
+	notFoundHandler     http.Handler
+	shutdownTimeout     time.Duration
+	signalHandling      bool
+	methodMiddleware    map[string][]MiddlewareFunc
+	cors                *corsOpts
+	corsCredentials     bool
+	mergeQueryParams    bool
+	queryPrecedence     QueryPrecedence
+	clientInt64AsString bool
+	anyResolver         jsonpb.AnyResolver
+	rejectDuplicateKeys bool
+	validateRequests    bool
+	validators          []func(ctx context.Context, method string, msg proto.Message) error
+	unaryInterceptors   []grpc.UnaryServerInterceptor
+	clientTimeouts      bool
+	contentType         string
+	errorContentType    string
+	httpServerConfigs   []func(*http.Server)
+	queryDecoder        QueryDecoderFunc
+	logger              LeveledLogger
+	quiet               bool
 }
 
+// isAllowedMethod reports whether the method with the given short name (e.g. "Add") is allowed by the AllowedMethods option.
 func (s *serverOpts) isAllowedMethod(methodName string) bool {
 	if len(s.allowedMethods) < 1 {
 		return true
@@ -63,21 +153,142 @@ func (s *serverOpts) isAllowedMethod(methodName string) bool {
 	return false
 }
 
+// methodTimeout returns the request timeout of methodName, falling back to the Timeout option.
+func (s *serverOpts) methodTimeout(methodName string) time.Duration {
+	if timeout, ok := s.methodTimeouts[methodName]; ok {
+		return timeout
+	}
+	return s.timeout
+}
+
+// successStatus returns the HTTP status code of the successful responses of methodName, see MethodSuccessStatus.
+func (s *serverOpts) successStatus(methodName string) int {
+	if code, ok := s.successStatuses[methodName]; ok {
+		return code
+	}
+	return http.StatusOK
+}
+
+// shortMethodName returns the method name of a fully qualified function name as returned by runtime.FuncForPC,
+// e.g. "Add" for "github.com/me/service.(*server).Add-fm".
+func shortMethodName(funcName string) string {
+	funcName = strings.TrimSuffix(funcName, "-fm")
+	if dot := strings.LastIndex(funcName, "."); dot >= 0 {
+		return funcName[dot+1:]
+	}
+	return funcName
+}
+
+var defaultHTTPMethods = []string{"POST", "GET"}
+
+// allowedHTTPMethods returns the HTTP verbs accepted by endpoint.
+func (s *serverOpts) allowedHTTPMethods(endpoint string) []string {
+	if httpMethods, ok := s.httpMethods[endpoint]; ok {
+		return httpMethods
+	}
+	return defaultHTTPMethods
+}
+
+// supportedHTTPMethods are the HTTP verbs that requests can be decoded from.
+var supportedHTTPMethods = map[string]bool{"POST": true, "PUT": true, "PATCH": true, "GET": true, "DELETE": true}
+
+// isAllowedHTTPMethod reports whether endpoint accepts httpMethod, verbs that requests can't be decoded from are never accepted.
+func (s *serverOpts) isAllowedHTTPMethod(endpoint, httpMethod string) bool {
+	if !supportedHTTPMethods[httpMethod] {
+		return false
+	}
+	for _, method := range s.allowedHTTPMethods(endpoint) {
+		if httpMethod == method {
+			return true
+		}
+	}
+	return false
+}
+
+// writeMethodNotAllowed answers a request with a verb that endpoint doesn't accept with a 405 and an Allow header listing the accepted verbs.
+func writeMethodNotAllowed(w http.ResponseWriter, endpoint string, httpServerOpts *serverOpts) {
+	var allowed []string
+	for _, method := range httpServerOpts.allowedHTTPMethods(endpoint) {
+		if supportedHTTPMethods[method] {
+			allowed = append(allowed, method)
+		}
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// optionsHandler answers OPTIONS requests with a 204 and the verbs accepted by the endpoint in the Allow header,
+// without decoding the request or calling the method.
+func optionsHandler(handler http.Handler, allow string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
 // The MiddlewareFunc type is for use in the Middlware option
 type MiddlewareFunc func(http.Handler) http.Handler
 
+// applyMiddlewareTo wraps handler with the middleware handlers so that the first one is the outermost:
+// it runs first on the request and last on the response.
 func applyMiddlewareTo(handler http.Handler, middlewareHandlers []MiddlewareFunc) http.Handler {
 	next := handler
-	for _, middlewareHandler := range middlewareHandlers {
-		next = middlewareHandler(next)
+	for i := len(middlewareHandlers) - 1; i >= 0; i-- {
+		next = middlewareHandlers[i](next)
 	}
 	return next
 }
 
-func reverse(s []MiddlewareFunc) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
+// wrapHandler applies the panic recovery, compression, metrics, method middleware, middleware and CORS handlers to an RPC handler
+// and stores the method name on the request context, see MethodFromContext. OPTIONS requests are answered with allow
+// as the Allow header, unless it is empty.
+func wrapHandler(handler http.Handler, methodName, allow string, httpServerOpts *serverOpts) http.Handler {
+	// OPTIONS requests that are not CORS preflight requests go through the middleware handlers like any request.
+	if allow != "" {
+		handler = optionsHandler(handler, allow)
+	}
+	if ttl, ok := httpServerOpts.cacheTTLs[methodName]; ok && httpServerOpts.responseCache != nil {
+		handler = httpServerOpts.responseCache.handler(handler, ttl, httpServerOpts)
+	}
+	if httpServerOpts.recoverPanics {
+		handler = recoverHandler(handler, httpServerOpts)
 	}
+	if httpServerOpts.compression {
+		handler = compressHandler(handler, httpServerOpts.compressionLevel, httpServerOpts.compressionMinSize)
+	}
+	if httpServerOpts.metrics != nil {
+		handler = httpServerOpts.metrics.instrument(handler, methodName)
+	}
+	if httpServerOpts.otelMetrics != nil {
+		handler = httpServerOpts.otelMetrics.instrument(handler, methodName)
+	}
+	// Method middleware runs inside the global middleware chain.
+	handler = applyMiddlewareTo(handler, httpServerOpts.methodMiddleware[methodName])
+	handler = applyMiddlewareTo(handler, httpServerOpts.middlewareHandlers)
+	// Preflight requests are answered before the middleware handlers, which may reject them (e.g. for authentication).
+	if httpServerOpts.cors != nil {
+		handler = corsHandler(handler, httpServerOpts.cors, httpServerOpts.corsCredentials)
+	}
+	next := handler
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), methodKey{}, methodName)
+		ctx = context.WithValue(ctx, clientIPKey{}, httpServerOpts.clientIP(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type methodKey struct{}
+
+// MethodFromContext returns the name of the RPC method (e.g. "Add") that a request is routed to, regardless of its URL path
+// which depends on the EndpointNamer, PathPrefix and AddEndpoints options. It can be used with the HTTP request context
+// in middleware as well as with the context passed to RPC methods and interceptors.
+func MethodFromContext(ctx context.Context) (string, bool) {
+	methodName, ok := ctx.Value(methodKey{}).(string)
+	return methodName, ok
 }
 
 // Port allows setting the HTTP server port. Default is ":8080".
@@ -87,22 +298,275 @@ func Port(port string) func(*serverOpts) {
 	}
 }
 
+// Listener allows serving on an existing net.Listener (e.g. a Unix domain socket or a TCP listener on ":0").
+// When a Listener is set, Serve accepts connections on it and the Port option is ignored.
+func Listener(listener net.Listener) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.listener = listener
+	}
+}
+
+// TLS allows serving HTTPS using the certificate and matching private key in the given PEM files.
+func TLS(certFile, keyFile string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.tlsCertFile = certFile
+		s.tlsKeyFile = keyFile
+	}
+}
+
+// TLSConfig allows serving HTTPS with the given TLS configuration, e.g. to load certificates from memory or to set the minimum TLS version.
+// The certificates can either be set in the config or loaded from files with the TLS option.
+func TLSConfig(config *tls.Config) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.tlsConfig = config
+	}
+}
+
 // Timeout allows setting the HTTP request timeout. Default is 30 seconds.
+// The context passed to RPC methods is also cancelled when the client disconnects before the request completes.
+// Methods that return the error of the context when it times out (e.g. ctx.Err()) are answered with a 504 Gateway Timeout.
+// The timeouts of the connections themselves are set on the http.Server, see HTTPServerConfig.
 func Timeout(timeout time.Duration) func(*serverOpts) {
 	return func(s *serverOpts) {
 		s.timeout = timeout
 	}
 }
 
+// HTTPServerConfig allows configuring the underlying http.Server before it starts serving, e.g. to tune its timeouts:
+//
+//	grpcj.HTTPServerConfig(func(s *http.Server) { s.WriteTimeout = 2 * time.Minute })
+//
+// The server is created with a ReadHeaderTimeout of 10 seconds, a ReadTimeout of 1 minute and an IdleTimeout of 2 minutes
+// to protect against slow clients and leaked connections. WriteTimeout is not set by default because it would cut
+// streaming responses short, set it when the service has no streaming methods.
+// Unlike these socket-level timeouts, the Timeout option limits how long the RPC method may run.
+func HTTPServerConfig(config func(*http.Server)) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.httpServerConfigs = append(s.httpServerConfigs, config)
+	}
+}
+
+// ShutdownTimeout allows limiting how long Serve waits for in-flight requests to complete when shutting down on a signal.
+// Connections that are still active after the timeout are closed. Default is 0, which waits until all requests complete.
+func ShutdownTimeout(timeout time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.shutdownTimeout = timeout
+	}
+}
+
+// DisableSignalHandling stops Serve from handling SIGINT and SIGTERM and re-emitting them after shutting down, for applications that manage signals themselves.
+// Serve then runs until the server is closed, e.g. by closing the listener passed to the Listener option.
+// Use NewServer instead to call Shutdown on the server directly.
+func DisableSignalHandling() func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.signalHandling = false
+	}
+}
+
+// MethodTimeout allows overriding the HTTP request timeout for a specific method (e.g. MethodTimeout(server.GenerateReport, 90*time.Second)).
+// Methods without an override use the Timeout option.
+func MethodTimeout(method interface{}, timeout time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.methodTimeouts == nil {
+			s.methodTimeouts = map[string]time.Duration{}
+		}
+		methodName := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+		s.methodTimeouts[shortMethodName(methodName)] = timeout
+	}
+}
+
+// MethodSuccessStatus allows overriding the HTTP status code of the successful responses of a method, which is 200 by default
+// (e.g. MethodSuccessStatus(server.CreateAccount, http.StatusCreated)). The method is matched by name like MethodTimeout.
+// Errors are still written with the status code derived from their gRPC status.
+func MethodSuccessStatus(method interface{}, code int) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.successStatuses == nil {
+			s.successStatuses = map[string]int{}
+		}
+		methodName := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
+		s.successStatuses[shortMethodName(methodName)] = code
+	}
+}
+
+// PoolRequests allows reusing the request messages of unary methods across requests to reduce allocations under load.
+// A request message is reset and returned to a pool of its type once the response has been written, so methods and interceptors
+// must not keep references to the request or to its fields (e.g. in a goroutine) after they return.
+// It is disabled by default.
+func PoolRequests(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.poolRequests = enabled
+	}
+}
+
+// EmptyNoContent allows responding with a 204 No Content and no body to methods that return google.protobuf.Empty,
+// instead of a 200 with a {} body. It is disabled by default for clients that expect a JSON body.
+// A status set with MethodSuccessStatus takes precedence over the 204, the body is left out either way.
+func EmptyNoContent(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.emptyNoContent = enabled
+	}
+}
+
+// MaxRequestBodySize allows limiting the size in bytes of POST, PUT and PATCH request bodies. Default is 4MB.
+// Requests with a larger body are rejected with a 413 Request Entity Too Large. A size of 0 or less disables the limit.
+func MaxRequestBodySize(size int64) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.maxRequestBodySize = size
+	}
+}
+
+// QueryPrecedence defines which of the query parameters or the body wins when both set the same field, see MergeQueryParams.
+type QueryPrecedence int
+
+const (
+	// QueryOverridesBody sets the fields found in the query parameters over the fields decoded from the body. This is the default.
+	QueryOverridesBody QueryPrecedence = iota
+	// BodyOverridesQuery only sets the fields found in the query parameters that are not set by the body.
+	BodyOverridesQuery
+)
+
+// MergeQueryParams allows decoding the query parameters of POST, PUT and PATCH requests along with the body.
+// The body is decoded first, then the query parameters are parsed the same way as for GET requests and merged into the request message
+// (e.g. POST /List?page=2&limit=50 with a JSON body). By default the query parameters override the body, see QueryParamsPrecedence.
+// The precedence applies to top-level fields: a nested message or repeated field set by the winning side replaces the other one entirely.
+func MergeQueryParams(merge bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.mergeQueryParams = merge
+	}
+}
+
+// QueryParamsPrecedence allows defining whether the query parameters or the body win when MergeQueryParams is enabled
+// and both set the same field. Default is QueryOverridesBody.
+func QueryParamsPrecedence(precedence QueryPrecedence) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.queryPrecedence = precedence
+	}
+}
+
 // Marshaler allows defining the JSON marshaler. Default marshaler is the github.com/zang-cloud/grpc-json/jsonpb.go Marshaler{EnumsAsInts: true, EmitDefaults: true, OrigName: true, Int64AsString: false, Uint64AsString: false}.
 // The Marshaler is a copy of the github.com/golang/protobuf/jsonpb/jsonpb.go Marshaler but adds 2 options: Int64AsString and Uint64AsString.
 // These options were added to allow returning Int64 and Uint64 as numbers instead of strings.
+// Timestamps are written as RFC 3339 strings by default, set TimestampFormat to jsonpb.TimestampRFC3339 or jsonpb.TimestampUnixMillis
+// to write them truncated to the second or as milliseconds since the Unix epoch. The jsonpb Unmarshaler accepts all these formats.
+// Messages generated by gogo/protobuf are written with the MarshalerGOGO variant of a jsonpb Marshaler, so that their stdtime
+// fields are written like Timestamps, and are read with the UnmarshalerGOGO variant of a jsonpb Unmarshaler.
 func Marshaler(marshaler JSONPBMarshaler) func(*serverOpts) {
 	return func(s *serverOpts) {
 		s.marshaler = marshaler
 	}
 }
 
+// RuntimeMarshaler allows using a grpc-gateway runtime.Marshaler (e.g. &runtime.JSONPb{OrigName: true}) to encode the responses
+// and decode the request bodies, so that the JSON of the server is identical to the one of a grpc-gateway mux configured
+// with the same marshaler. It overrides the Marshaler and Unmarshaler options regardless of their order, and the options
+// that change the jsonpb marshalers, such as AllowUnknownFields and ClientInt64AsString, have no effect.
+// The Content-Type of the responses is still set by the ContentType option.
+func RuntimeMarshaler(marshaler gwruntime.Marshaler) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.runtimeMarshaler = marshaler
+	}
+}
+
+// runtimeMarshaler adapts a grpc-gateway runtime.Marshaler to JSONPBMarshaler and JSONPBUnmarshaler.
+type runtimeMarshaler struct {
+	marshaler gwruntime.Marshaler
+}
+
+func (m runtimeMarshaler) Marshal(w io.Writer, v interface{}) error {
+	data, err := m.marshaler.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Unmarshal decodes a request body the way grpc-gateway does, with a decoder of the marshaler.
+func (m runtimeMarshaler) Unmarshal(r io.Reader, v interface{}) error {
+	return m.marshaler.NewDecoder(r).Decode(v)
+}
+
+// Delimiter returns the delimiter of the marshaler between streamed messages, or a newline.
+func (m runtimeMarshaler) Delimiter() []byte {
+	if d, ok := m.marshaler.(gwruntime.Delimited); ok {
+		return d.Delimiter()
+	}
+	return []byte("\n")
+}
+
+// ContentType allows setting the Content-Type of successful JSON responses, e.g. "application/vnd.myapp+json". Default is application/json; charset=utf-8.
+// Responses in the protobuf wire format keep the protobuf content type negotiated with the client.
+func ContentType(contentType string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.contentType = contentType
+	}
+}
+
+// ErrorContentType allows setting the Content-Type of JSON error responses when it differs from the ContentType option,
+// which is used for errors by default. Plain text errors (when JSONErrors is disabled) are not affected.
+func ErrorContentType(contentType string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.errorContentType = contentType
+	}
+}
+
+// jsonErrorContentType returns the Content-Type of JSON error responses.
+func (s *serverOpts) jsonErrorContentType() string {
+	if s.errorContentType != "" {
+		return s.errorContentType
+	}
+	return s.contentType
+}
+
+const (
+	int64AsStringParam  = "int64_as_string"
+	int64AsStringHeader = "X-Int64-As-String"
+)
+
+// ClientInt64AsString allows clients to choose whether the int64 and uint64 fields of the response are written as strings or numbers,
+// for example JavaScript clients that would lose precision on large numbers. The request sets the "int64_as_string" query parameter
+// or the X-Int64-As-String header to true or false, the query parameter wins when both are set and is not decoded into the request message.
+// The Int64AsString and Uint64AsString options of a copy of the marshaler are set for that request only, the marshaler must be a jsonpb Marshaler
+// or MarshalerGOGO for the choice to have an effect. Requests that don't set either use the configured marshaler unchanged.
+func ClientInt64AsString(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.clientInt64AsString = enabled
+	}
+}
+
+// requestMarshaler returns the marshaler for the response of r, see ClientInt64AsString.
+// The int64_as_string query parameter is removed from r so it's not decoded into the request message.
+func (s *serverOpts) requestMarshaler(r *http.Request) JSONPBMarshaler {
+	if !s.clientInt64AsString {
+		return s.marshaler
+	}
+	value := r.Header.Get(int64AsStringHeader)
+	if query := r.URL.Query(); query.Has(int64AsStringParam) {
+		value = query.Get(int64AsStringParam)
+		query.Del(int64AsStringParam)
+		r.URL.RawQuery = query.Encode()
+	}
+	asString, err := strconv.ParseBool(value)
+	if err != nil {
+		return s.marshaler
+	}
+	return withMarshalerOptions(s.marshaler, jsonpb.WithInt64AsString(asString), jsonpb.WithUint64AsString(asString))
+}
+
+// withMarshalerOptions returns a copy of a jsonpb Marshaler or MarshalerGOGO with the given options applied,
+// other marshalers are returned unchanged.
+func withMarshalerOptions(marshaler JSONPBMarshaler, opts ...jsonpb.MarshalerOption) JSONPBMarshaler {
+	switch m := marshaler.(type) {
+	case *jsonpb.Marshaler:
+		copied := m.With(opts...)
+		return &copied
+	case *jsonpb.MarshalerGOGO:
+		copied := m.With(opts...)
+		return &copied
+	}
+	return marshaler
+}
+
 // Unmarshaler allows defining the JSON unmarshaler. Default unmarshaler is the github.com/zang-cloud/grpc-json/jsonpb.go Unmarshaler{AllowUnknownFields: false}.
 // The Unmarshaler is a copy of the github.com/golang/protobuf/jsonpb/jsonpb.go Unmarshaler.
 func Unmarshaler(unmarshaler JSONPBUnmarshaler) func(*serverOpts) {
@@ -111,40 +575,217 @@ func Unmarshaler(unmarshaler JSONPBUnmarshaler) func(*serverOpts) {
 	}
 }
 
+// AllowUnknownFields allows defining whether request fields that are not in the request message are ignored or rejected with a 400.
+// Default is false, unknown fields are rejected. It sets the flag on a copy of the current jsonpb Unmarshaler (or UnmarshalerGOGO),
+// so it composes with the Unmarshaler option in order: AllowUnknownFields after Unmarshaler changes that unmarshaler,
+// while Unmarshaler after AllowUnknownFields replaces it. It has no effect on other unmarshalers.
+func AllowUnknownFields(allow bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		switch u := s.unmarshaler.(type) {
+		case *jsonpb.Unmarshaler:
+			unmarshaler := *u
+			unmarshaler.AllowUnknownFields = allow
+			s.unmarshaler = &unmarshaler
+		case *jsonpb.UnmarshalerGOGO:
+			unmarshaler := *u
+			unmarshaler.AllowUnknownFields = allow
+			s.unmarshaler = &unmarshaler
+		}
+	}
+}
+
+// AnyResolver allows defining how the messages embedded in google.protobuf.Any fields are resolved from their type URL,
+// for both the requests and the responses. By default the type is looked up by the name after the last slash of the URL
+// in the registered proto messages, the resolver allows serving types that are not registered or use custom type URLs.
+// It applies to the jsonpb Marshaler and Unmarshaler (or their GOGO variants) whether they are the default ones or are set with the
+// Marshaler and Unmarshaler options, a copy of them is used so the resolver can be set on DefaultMarshaler without modifying it.
+func AnyResolver(resolver jsonpb.AnyResolver) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.anyResolver = resolver
+	}
+}
+
+// applyAnyResolver sets the AnyResolver option on copies of the marshaler and unmarshaler.
+func (s *serverOpts) applyAnyResolver() {
+	s.marshaler = withMarshalerOptions(s.marshaler, jsonpb.WithAnyResolver(s.anyResolver))
+	switch u := s.unmarshaler.(type) {
+	case *jsonpb.Unmarshaler:
+		unmarshaler := *u
+		unmarshaler.AnyResolver = s.anyResolver
+		s.unmarshaler = &unmarshaler
+	case *jsonpb.UnmarshalerGOGO:
+		unmarshaler := *u
+		unmarshaler.AnyResolver = s.anyResolver
+		s.unmarshaler = &unmarshaler
+	}
+}
+
 // AddEndpoints allows adding endpoints that are mapped to GRPC methods. It takes a map of URL path to GRPC method.
 // The URL path must include the starting / (e.g. "/MyAddedEndpoint").
+//
+// The URL path can contain wildcard segments, as supported by http.ServeMux patterns, that are bound to fields of the request message.
+// The wildcard is named after the proto or JSON name of a scalar or enum field, which is set to the value of the segment
+// after the body or query parameters are decoded. For example, to serve GetUser at /users/42 with the user_id field set to 42:
+//
+//	grpcj.AddEndpoints(map[string]interface{}{"/users/{user_id}": server.GetUser})
 func AddEndpoints(endpointToMethodMap map[string]interface{}) func(*serverOpts) {
 	return func(s *serverOpts) {
 		s.endpointToMethodMap = endpointToMethodMap
 	}
 }
 
+// DisableAutoRegistration stops serving the methods of the gRPC server at their default endpoints (e.g. /Add),
+// so that only the endpoints added with AddEndpoints are served, along with the healthchecks and the metrics endpoint.
+// Unlike AllowedMethods, which restricts the methods that can be called, it lets the URL surface be listed explicitly.
+func DisableAutoRegistration() func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.autoRegistration = false
+	}
+}
+
+// OverrideDefaultEndpoints allows the paths given to AddEndpoints to replace the default endpoint of their method
+// (e.g. /CreateUser) instead of being served in addition to it. Default is false.
+// Methods are matched by name, so when serving several servers the default endpoints of all the methods with that name are removed.
+// To keep serving the default endpoint during a migration, leave it enabled and mark it with DeprecatedEndpoint instead.
+func OverrideDefaultEndpoints(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.overrideDefaults = enabled
+	}
+}
+
+// PathPrefix allows serving all the endpoints under a base path, e.g. PathPrefix("/api/v1") serves the Add method at /api/v1/Add.
+// The prefix applies to the RPC methods, the added endpoints, the healthchecks and the metrics endpoint.
+// Leading and trailing slashes are normalized so PathPrefix("api/v1/") is the same as PathPrefix("/api/v1").
+// Other options that take an endpoint (e.g. AllowedHTTPMethods) still expect the path without the prefix.
+func PathPrefix(prefix string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		prefix = strings.Trim(prefix, "/")
+		if prefix != "" {
+			prefix = "/" + prefix
+		}
+		s.pathPrefix = prefix
+	}
+}
+
+// EndpointNamer allows controlling how the name of an RPC method maps to the URL path it is served at, the path is "/" followed by the returned name.
+// Default is IdentityNamer, which serves GetUserProfile at /GetUserProfile. Use LowerNamer or SnakeCaseNamer to serve it at /getuserprofile or /get_user_profile.
+// Endpoints added with AddEndpoints are not renamed, and AllowedMethods and MethodTimeout still match on the Go method name.
+func EndpointNamer(namer func(methodName string) string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.endpointNamer = namer
+	}
+}
+
+// IdentityNamer is an EndpointNamer that uses the method name as is.
+func IdentityNamer(methodName string) string {
+	return methodName
+}
+
+// LowerNamer is an EndpointNamer that lowercases the method name, e.g. GetUserProfile becomes getuserprofile.
+func LowerNamer(methodName string) string {
+	return strings.ToLower(methodName)
+}
+
+// SnakeCaseNamer is an EndpointNamer that converts the method name to snake_case, e.g. GetUserProfile becomes get_user_profile
+// and GetHTTPStatus becomes get_http_status.
+func SnakeCaseNamer(methodName string) string {
+	runes := []rune(methodName)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// routePath returns the path at which endpoint is served, taking the PathPrefix option into account.
+func (s *serverOpts) routePath(endpoint string) string {
+	return s.pathPrefix + endpoint
+}
+
 // AllowedMethods allows restricting access to only the defined methods.
 // Pass in a slice of methods (e.g. AllowedMethods([]interface{}{server.Add})).
+// The methods are matched by name like MethodTimeout, the other methods are not served, neither at their default endpoint
+// nor at the endpoints added with AddEndpoints. When serving several servers, the methods with an allowed name are allowed on all of them.
+// Requests to the endpoints of the other methods are answered with a JSON 404, see DisallowedMethodCode.
 func AllowedMethods(allowedMethods []interface{}) func(*serverOpts) {
 	return func(s *serverOpts) {
 		for _, method := range allowedMethods {
 			methodName := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
-			s.allowedMethods = append(s.allowedMethods, methodName)
+			s.allowedMethods = append(s.allowedMethods, shortMethodName(methodName))
 		}
 	}
 }
 
-var healthcheckStatus int = http.StatusOK
+// DisallowedMethodCode allows choosing how requests to the endpoints of the methods excluded by AllowedMethods are answered.
+// With codes.NotFound, the default, they get the same JSON 404 as an unknown path (see NotFoundHandler), which doesn't reveal
+// that the method exists. With codes.PermissionDenied they get a 403 stating that the method is not exposed:
+//
+//	{"code": "PERMISSION_DENIED", "message": "method Subtract is not exposed", "details": []}
+func DisallowedMethodCode(code codes.Code) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.disallowedCode = code
+	}
+}
+
+// JSONErrors allows returning RPC errors as a JSON body instead of plain text. Default is false.
+// When enabled, errors are written with a Content-Type of application/json; charset=utf-8 (see ErrorContentType) in the form:
+//
+//	{"code": "INVALID_ARGUMENT", "message": "num_one must be positive", "details": []}
+//
+// The code and message are taken from the gRPC status of the error and any status details are marshaled with the configured marshaler,
+// along with an @type field naming their type, e.g. the field violations of a google.rpc.BadRequest:
+//
+//	{"code": "INVALID_ARGUMENT", "message": "invalid user", "details": [
+//		{"@type": "type.googleapis.com/google.rpc.BadRequest", "field_violations": [{"field": "email", "description": "is required"}]}
+//	]}
+//
+// Errors that don't carry a gRPC status are returned with a code of "UNKNOWN".
+// In both modes the HTTP status code reflects the gRPC code of the error (e.g. codes.NotFound results in a 404).
+func JSONErrors(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.jsonErrors = enabled
+	}
+}
 
-// HealthCheck allows defining an endpoint for healthchecks as well as a function to be executed at defined intervals to check the health of the service.
-// The healthcheck function will be run at the defined intervals and will respond to http requests with 200 or 500 depending on the status of the healthcheck.
-// Ideally this function should check any external dependencies such as pinging mysql etc. and should return any error.
-// The endpoint name must include the starting / (e.g. "/MyHealtchCheck").
-func HealthCheck(endpoint string, healthcheckFunc func() error, healthcheckInterval time.Duration) func(*serverOpts) {
+// AllowedHTTPMethods allows defining which HTTP verbs are accepted by an endpoint. It takes a map of URL path to HTTP verbs.
+// The URL path must include the starting / (e.g. "/Add").
+// POST, PUT and PATCH requests unmarshal the JSON body into the request message,
+// GET and DELETE requests unmarshal the query parameters into the request message.
+// Endpoints that are not in the map accept POST and GET, which is also the default for all endpoints.
+// Unary endpoints that accept GET also answer HEAD requests with the headers of the GET response and no body.
+// OPTIONS requests that are not CORS preflight requests are answered with a 204 and an Allow header listing the accepted verbs,
+// after the middleware handlers.
+// Requests with any other verb are answered with a 405 Method Not Allowed and an Allow header listing the accepted verbs.
+//
+// For example, to additionally accept PUT on /UpdateUser and only DELETE on /DeleteUser:
+//
+//	grpcj.AllowedHTTPMethods(map[string][]string{
+//		"/UpdateUser": {"POST", "PUT"},
+//		"/DeleteUser": {"DELETE"},
+//	})
+func AllowedHTTPMethods(endpointToHTTPMethods map[string][]string) func(*serverOpts) {
 	return func(s *serverOpts) {
-		s.healthcheckEndpoint = endpoint
-		s.healthcheckFunc = healthcheckFunc
-		s.healthcheckInterval = healthcheckInterval
+		if s.httpMethods == nil {
+			s.httpMethods = map[string][]string{}
+		}
+		for endpoint, httpMethods := range endpointToHTTPMethods {
+			s.httpMethods[endpoint] = nil
+			for _, httpMethod := range httpMethods {
+				s.httpMethods[endpoint] = append(s.httpMethods[endpoint], strings.ToUpper(httpMethod))
+			}
+		}
 	}
 }
 
-// Middleware registers a middleware handler. Any number of middleware handlers can be passed in and they will be called in order.
+// Middleware registers a middleware handler. Any number of middleware handlers can be passed in and they will be called in order:
+// the first registered handler is the outermost one, it runs first on the request and last on the response.
+// Passing the option several times appends to the handlers already registered.
 // A middleware handler must have a signature of func(http.Handler) http.Handler.
 //
 // An example middleware handler:
@@ -175,7 +816,21 @@ func Middleware(handlers ...MiddlewareFunc) func(*serverOpts) {
 	}
 }
 
+// MethodMiddleware registers middleware handlers that only apply to the given method (e.g. MethodMiddleware(server.DeleteAccount, auth)).
+// The method is matched by name like MethodTimeout, so it also applies to the endpoints added for the method with AddEndpoints.
+// Method middleware runs after the global Middleware handlers, in the order it is registered, just before the RPC method is called.
+func MethodMiddleware(method interface{}, handlers ...MiddlewareFunc) func(*serverOpts) {
+	return func(s *serverOpts) {
+		if s.methodMiddleware == nil {
+			s.methodMiddleware = map[string][]MiddlewareFunc{}
+		}
+		methodName := shortMethodName(runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name())
+		s.methodMiddleware[methodName] = append(s.methodMiddleware[methodName], handlers...)
+	}
+}
+
 // BasicAuth is a MiddlewareFunc that enforces basic auth.
+// See JWTAuth and APIKeyAuth for bearer token and API key authentication.
 func BasicAuth(username, password string) MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -204,134 +859,589 @@ func applyOptions(options []func(*serverOpts)) *serverOpts {
 		marshaler:          DefaultMarshaler,
 		unmarshaler:        DefaultUnmarshaler,
 		middlewareHandlers: []MiddlewareFunc{},
+		headerToMetadata:   DefaultHeaderToMetadata,
+		metadataToHeader:   DefaultMetadataToHeader,
+		sseHeartbeat:       defaultSSEHeartbeat,
+		responseCacheSize:  defaultResponseCacheSize,
+		recoverPanics:      true,
+		compressionMinSize: defaultCompressionMinSize,
+		maxRequestBodySize: defaultMaxRequestBodySize,
+		endpointNamer:      IdentityNamer,
+		signalHandling:     true,
+		autoRegistration:   true,
+		disallowedCode:     codes.NotFound,
+		contentType:        defaultContentType,
+		logger:             defaultLogger(),
 	}
 	for _, opt := range options {
 		opt(httpServerOpts)
 	}
+	if httpServerOpts.runtimeMarshaler != nil {
+		adapter := runtimeMarshaler{marshaler: httpServerOpts.runtimeMarshaler}
+		httpServerOpts.marshaler, httpServerOpts.unmarshaler = adapter, adapter
+	}
+	if httpServerOpts.anyResolver != nil {
+		httpServerOpts.applyAnyResolver()
+	}
 	return httpServerOpts
 }
 
-// Serve will start an HTTP server and serve the RPC methods.
-func Serve(grpcServer interface{}, options ...func(*serverOpts)) {
-	httpServerOpts := applyOptions(options)
-	reverse(httpServerOpts.middlewareHandlers)
-	grpcServerType := reflect.TypeOf(grpcServer)
+// NewServer will build an HTTP server that serves the RPC methods without starting it.
+// Unlike Serve, no signal handlers are installed, the caller is responsible for calling ListenAndServe and Shutdown on the returned server.
+// The healthchecks are stopped by Shutdown.
+func NewServer(grpcServer interface{}, options ...func(*serverOpts)) (*http.Server, error) {
+	return newServer([]interface{}{grpcServer}, applyOptions(options))
+}
+
+// NewServerAll is like NewServer but serves the RPC methods of several servers on the same HTTP server, see ServeAll.
+func NewServerAll(grpcServers []interface{}, options ...func(*serverOpts)) (*http.Server, error) {
+	return newServer(grpcServers, applyOptions(options))
+}
+
+// Handler builds the handler that serves the RPC methods of grpcServer, with the middleware, healthchecks, metrics
+// and added endpoints registered, so it can be mounted in an existing router alongside other routes:
+//
+//	handler, err := grpcj.Handler(server, grpcj.PathPrefix("/api"))
+//	mux.Handle("/api/", handler)
+//
+// The options that configure the http.Server itself, such as Port, TLS and HTTPServerConfig, are ignored.
+// The healthchecks run for the lifetime of the process.
+func Handler(grpcServer interface{}, options ...func(*serverOpts)) (http.Handler, error) {
+	return newHandler([]interface{}{grpcServer}, applyOptions(options))
+}
+
+func newServer(grpcServers []interface{}, httpServerOpts *serverOpts) (*http.Server, error) {
+	mux, err := newHandler(grpcServers, httpServerOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	serverHTTP := &http.Server{
+		Addr:              httpServerOpts.port,
+		Handler:           mux,
+		TLSConfig:         httpServerOpts.tlsConfig,
+		ReadHeaderTimeout: defaultReadHeaderTimeout,
+		ReadTimeout:       defaultReadTimeout,
+		IdleTimeout:       defaultIdleTimeout,
+	}
+	for _, config := range httpServerOpts.httpServerConfigs {
+		config(serverHTTP)
+	}
+	serverHTTP.RegisterOnShutdown(httpServerOpts.stopHealthchecks)
+	return serverHTTP, nil
+}
+
+// newHandler registers the routes of grpcServers on a new ServeMux and starts the healthchecks.
+func newHandler(grpcServers []interface{}, httpServerOpts *serverOpts) (*http.ServeMux, error) {
+	if httpServerOpts.trustedProxiesErr != nil {
+		return nil, httpServerOpts.trustedProxiesErr
+	}
+	if httpServerOpts.compression && !isValidCompressionLevel(httpServerOpts.compressionLevel) {
+		return nil, fmt.Errorf("grpcj: invalid compression level %d", httpServerOpts.compressionLevel)
+	}
+	for _, check := range httpServerOpts.healthchecks {
+		if check.interval <= 0 {
+			return nil, fmt.Errorf("grpcj: interval of healthcheck %s must be positive, got %s", check.endpoint, check.interval)
+		}
+	}
+	routes, err := discoverRoutes(grpcServers, httpServerOpts)
+	if err != nil {
+		return nil, err
+	}
+
 	mux := http.NewServeMux()
 
-	for i := 0; i < grpcServerType.NumMethod(); i++ {
-		methodName := grpcServerType.Method(i).Name
-		if httpServerOpts.isAllowedMethod(methodName) {
-			methodFunc := reflect.ValueOf(grpcServer).MethodByName(methodName)
-			handler := grpcjHandler(methodFunc, httpServerOpts)
-			mux.HandleFunc("/"+methodName, applyMiddlewareTo(handler, httpServerOpts.middlewareHandlers).ServeHTTP)
+	if httpServerOpts.metricsRegisterer != nil {
+		m, err := newMetrics(httpServerOpts.metricsRegisterer)
+		if err != nil {
+			return nil, err
+		}
+		httpServerOpts.metrics = m
+		if httpServerOpts.metricsEndpoint != "" {
+			if err := handle(mux, httpServerOpts.routePath(httpServerOpts.metricsEndpoint), metricsHandler(httpServerOpts.metricsRegisterer), "the metrics endpoint"); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	for endpoint, method := range httpServerOpts.endpointToMethodMap {
-		methodName := runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name()
-		if httpServerOpts.isAllowedMethod(methodName) {
-			methodFunc := reflect.ValueOf(method)
-			handler := grpcjHandler(methodFunc, httpServerOpts)
-			mux.HandleFunc(endpoint, applyMiddlewareTo(handler, httpServerOpts.middlewareHandlers).ServeHTTP)
-		}
-	}
-
-	if httpServerOpts.healthcheckFunc != nil {
-		go func() {
-			for _ = range time.Tick(httpServerOpts.healthcheckInterval) {
-				if err := httpServerOpts.healthcheckFunc(); err != nil {
-					logrus.Errorln("Healthcheck failed:", err)
-					healthcheckStatus = http.StatusInternalServerError
-				} else {
-					if healthcheckStatus != http.StatusOK {
-						logrus.Infoln("Healthcheck recovered")
-					}
-					healthcheckStatus = http.StatusOK
-				}
+	if httpServerOpts.meterProvider != nil {
+		m, err := newOTelMetrics(httpServerOpts.meterProvider)
+		if err != nil {
+			return nil, err
+		}
+		httpServerOpts.otelMetrics = m
+	}
+
+	if len(httpServerOpts.cacheTTLs) > 0 {
+		httpServerOpts.responseCache = newResponseCache(httpServerOpts.responseCacheSize)
+	}
+
+	for _, r := range routes {
+		var handler http.Handler
+		if r.streamDesc != nil && r.streamDesc.ClientStreams && r.streamDesc.ServerStreams {
+			handler = webSocketHandler(r.endpoint, r.server, r.streamDesc, httpServerOpts)
+		} else if r.streamDesc != nil && r.streamDesc.ClientStreams {
+			handler = clientStreamHandler(r.endpoint, r.server, r.streamDesc, httpServerOpts)
+		} else if r.streamDesc != nil {
+			handler = serverStreamHandler(r.endpoint, r.server, r.streamDesc, httpServerOpts)
+		} else {
+			var err error
+			if handler, err = grpcjHandler(r.server, r.endpoint, r.methodName, r.methodFunc, httpServerOpts); err != nil {
+				return nil, err
 			}
-		}()
-		mux.HandleFunc(httpServerOpts.healthcheckEndpoint, func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(healthcheckStatus)
-		})
+		}
+		handler = wrapHandler(handler, r.methodName, r.allowHeader(httpServerOpts), httpServerOpts)
+		if d, ok := httpServerOpts.deprecations[r.endpoint]; ok {
+			handler = d.handler(handler)
+		}
+		if err := handle(mux, httpServerOpts.routePath(r.endpoint), handler, "method "+r.describe()); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range disallowedRoutes(grpcServers, routes, httpServerOpts) {
+		handler := wrapHandler(disallowedHandler(r.methodName, httpServerOpts), r.methodName, "", httpServerOpts)
+		if err := handle(mux, httpServerOpts.routePath(r.endpoint), handler, "method "+r.describe()); err != nil {
+			return nil, err
+		}
 	}
 
-	serverHTTP := &http.Server{Addr: httpServerOpts.port, Handler: mux}
+	for _, check := range httpServerOpts.healthchecks {
+		if err := handle(mux, httpServerOpts.routePath(check.endpoint), check, "a healthcheck"); err != nil {
+			return nil, err
+		}
+	}
+	for _, endpoint := range httpServerOpts.livenessEndpoints {
+		if err := handle(mux, httpServerOpts.routePath(endpoint), http.HandlerFunc(liveness), "a liveness endpoint"); err != nil {
+			return nil, err
+		}
+	}
+
+	if httpServerOpts.notFoundHandler != nil {
+		// The root pattern matches every path that no other endpoint is registered for.
+		if err := handle(mux, "/", httpServerOpts.notFoundHandler, "the NotFoundHandler"); err != nil {
+			return nil, err
+		}
+	}
+
+	// The healthchecks only start once the server can be built.
+	for _, check := range httpServerOpts.healthchecks {
+		check.logger = httpServerOpts.logger
+		for status, code := range httpServerOpts.healthStatusCodes {
+			check.statusCodes[status] = code
+		}
+		if httpServerOpts.healthStartUnknown {
+			check.result.Store(&healthcheckResult{status: http.StatusServiceUnavailable, body: healthcheckBody{Status: healthUnknown}})
+		}
+		go check.run()
+	}
+	return mux, nil
+}
+
+// handle registers handler on mux. http.ServeMux panics when a pattern is already registered or conflicts with another one,
+// which is returned as an error naming what was being registered instead.
+func handle(mux *http.ServeMux, pattern string, handler http.Handler, name string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("grpcj: can't serve %s at %s: %v", name, pattern, r)
+		}
+	}()
+	mux.Handle(pattern, handler)
+	return nil
+}
+
+// Serve will start an HTTP server and serve the RPC methods.
+// On SIGINT or SIGTERM the server is shut down gracefully (see ShutdownTimeout) and the signal is re-emitted, see DisableSignalHandling.
+func Serve(grpcServer interface{}, options ...func(*serverOpts)) {
+	ServeAll([]interface{}{grpcServer}, options...)
+}
+
+// ServeAll is like Serve but serves the RPC methods of several servers on the same HTTP server, e.g. when an API is split
+// across several gRPC services. The options apply to all the servers. ServeAll fails to start if two servers have a method
+// served at the same path. The endpoints added with AddEndpoints are passed a nil server in the grpc.UnaryServerInfo of the
+// UnaryInterceptors, since the server of a method value can't be determined.
+func ServeAll(grpcServers []interface{}, options ...func(*serverOpts)) {
+	httpServerOpts := applyOptions(options)
+	serverHTTP, err := newServer(grpcServers, httpServerOpts)
+	if err != nil {
+		httpServerOpts.logger.Errorf("Error creating grpc-json server: %v", err)
+		return
+	}
 
 	// Graceful shutdown.
 	idleConnsClosed := make(chan struct{})
-	exitChan := make(chan os.Signal, 1)
-	signal.Notify(exitChan, os.Interrupt, os.Kill)
-	go func() {
-		exitSignal := <-exitChan
-		fmt.Printf("Received shutdown signal '%s', attempting graceful shutdown of grpc-json server\n", exitSignal)
-		if err := serverHTTP.Shutdown(context.Background()); err != nil {
-			fmt.Println("Error gracefully shutting down grpc-json server:", err)
-		}
+	if httpServerOpts.signalHandling {
+		exitChan := make(chan os.Signal, 1)
+		// os.Kill can't be caught, orchestrators such as Kubernetes send SIGTERM to stop a process gracefully.
+		signal.Notify(exitChan, os.Interrupt, syscall.SIGTERM)
+		go handleSignals(serverHTTP, httpServerOpts, exitChan, idleConnsClosed)
+	} else {
 		close(idleConnsClosed)
+	}
+
+	useTLS := httpServerOpts.tlsCertFile != "" || httpServerOpts.tlsConfig != nil
+	switch {
+	case httpServerOpts.listener != nil && useTLS:
+		err = serverHTTP.ServeTLS(httpServerOpts.listener, httpServerOpts.tlsCertFile, httpServerOpts.tlsKeyFile)
+	case httpServerOpts.listener != nil:
+		err = serverHTTP.Serve(httpServerOpts.listener)
+	case useTLS:
+		err = serverHTTP.ListenAndServeTLS(httpServerOpts.tlsCertFile, httpServerOpts.tlsKeyFile)
+	default:
+		err = serverHTTP.ListenAndServe()
+	}
+	// Without signal handling the server is usually stopped by closing its listener.
+	if err != http.ErrServerClosed && !(errors.Is(err, net.ErrClosed) && !httpServerOpts.signalHandling) {
+		httpServerOpts.logger.Errorf("Error listening and serving grpc-json: %v", err)
+	}
+	// Shutdown stops the healthchecks, but not Close or a failure to listen.
+	httpServerOpts.stopHealthchecks()
+	<-idleConnsClosed
+}
+
+// handleSignals gracefully shuts down serverHTTP when an exit signal is received on exitChan and then re-emits the signal.
+// idleConnsClosed is closed once the server is shut down.
+func handleSignals(serverHTTP *http.Server, httpServerOpts *serverOpts, exitChan chan os.Signal, idleConnsClosed chan struct{}) {
+	exitSignal := <-exitChan
+	logger := httpServerOpts.lifecycleLogger()
+	logger.Infof("Received shutdown signal '%s', attempting graceful shutdown of grpc-json server", exitSignal)
+	shutdown(serverHTTP, httpServerOpts.shutdownTimeout, logger)
+	close(idleConnsClosed)
+
+	// We need to re-emit the exit signal because the normal use case is that
+	// grpc-json will be run in a goroutine and since it has hijacked the exit signal it must re-emit.
+	logger.Infof("Graceful shutdown of grpc-json complete, re-emitting exit signal %s", exitSignal)
+	signal.Stop(exitChan)
+	if currentProcess, err := os.FindProcess(os.Getpid()); err != nil {
+		logger.Errorf("Error getting current process to re-emit exit signal: %v", err)
+	} else {
+		currentProcess.Signal(exitSignal)
+	}
+}
+
+// shutdown gracefully shuts down serverHTTP, closing the remaining connections once the timeout expires. A timeout of 0 waits indefinitely.
+// It reports whether the shutdown completed cleanly.
+func shutdown(serverHTTP *http.Server, timeout time.Duration, logger LeveledLogger) bool {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	err := serverHTTP.Shutdown(ctx)
+	if err == nil {
+		logger.Infof("All requests completed, grpc-json server shut down cleanly")
+		return true
+	}
+	if err == context.DeadlineExceeded {
+		logger.Infof("Shutdown timeout of %s expired, forcing the remaining grpc-json connections closed", timeout)
+	} else {
+		logger.Errorf("Error gracefully shutting down grpc-json server: %v", err)
+	}
+	serverHTTP.Close()
+	return false
+}
+
+// limitRequestBody limits the size of the request body according to the MaxRequestBodySize option.
+func limitRequestBody(w http.ResponseWriter, r *http.Request, httpServerOpts *serverOpts) {
+	if httpServerOpts.maxRequestBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, httpServerOpts.maxRequestBodySize)
+	}
+}
 
-		// We need to re-emit the exit signal because the normal use case is that
-		// grpc-json will be run in a goroutine and since it has hijacked the exit signal it must re-emit.
-		fmt.Println("Graceful shutdown of grpc-json complete, re-emitting exit signal", exitSignal)
-		signal.Stop(exitChan)
-		if currentProcess, err := os.FindProcess(os.Getpid()); err != nil {
-			fmt.Println("Error getting current process to re-emit exit signal:", err)
+// decodeRequest unmarshals the query parameters of GET, HEAD and DELETE requests, or the JSON or protobuf body of any other request, into msg.
+func decodeRequest(r *http.Request, msg interface{}, httpServerOpts *serverOpts) error {
+	switch r.Method {
+	case "GET", "HEAD", "DELETE":
+		return decodeQuery(r, msg, httpServerOpts)
+	default:
+		defer r.Body.Close()
+		var err error
+		if isProtobufContentType(r.Header.Get("Content-Type")) {
+			err = unmarshalProtobuf(r, msg)
+		} else if httpServerOpts.rejectDuplicateKeys {
+			var body []byte
+			if body, err = readBodyWithoutDuplicateKeys(r); err == nil {
+				err = unmarshalerFor(httpServerOpts.unmarshaler, msg).Unmarshal(bytes.NewReader(body), msg)
+			}
 		} else {
-			currentProcess.Signal(exitSignal)
+			err = unmarshalerFor(httpServerOpts.unmarshaler, msg).Unmarshal(r.Body, msg)
 		}
-	}()
+		if err != nil || !httpServerOpts.mergeQueryParams || r.URL.RawQuery == "" {
+			return err
+		}
+		return mergeQueryParams(r, msg, httpServerOpts)
+	}
+}
 
-	if err := serverHTTP.ListenAndServe(); err != http.ErrServerClosed {
-		fmt.Println("Error listening and serving grpc-json:", err)
+// mergeQueryParams decodes the query parameters of r into a new message and sets its populated fields on msg,
+// which already holds the decoded body, according to the QueryParamsPrecedence option.
+func mergeQueryParams(r *http.Request, msg interface{}, httpServerOpts *serverOpts) error {
+	pb, ok := msg.(proto.Message)
+	if !ok {
+		return nil
 	}
-	<-idleConnsClosed
+	query := reflect.New(reflect.TypeOf(msg).Elem()).Interface().(proto.Message)
+	if err := decodeQuery(r, query, httpServerOpts); err != nil {
+		return err
+	}
+
+	body := proto.MessageReflect(pb)
+	proto.MessageReflect(query).Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if httpServerOpts.queryPrecedence == QueryOverridesBody || !body.Has(fd) {
+			body.Set(fd, v)
+		}
+		return true
+	})
+	return nil
+}
+
+var (
+	contextType      = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType        = reflect.TypeOf((*error)(nil)).Elem()
+	protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// isUnaryMethod reports whether a method has the shape of a unary RPC method: func(context.Context, Request) (Response, error).
+// Helper methods and streaming methods don't, the request and response types are checked when the handler is built.
+func isUnaryMethod(methodType reflect.Type) bool {
+	return methodType.NumIn() == 2 && methodType.In(0) == contextType &&
+		methodType.NumOut() == 2 && methodType.Out(1) == errorType
+}
+
+// methodPlan holds what serving an RPC method requires from reflection. It is computed once when the handler is built,
+// so that serving a request only allocates the request message and calls the method.
+type methodPlan struct {
+	methodFunc  reflect.Value
+	messageType reflect.Type
+	// isPointer is true when the method takes a pointer to the request message, false when it takes the message by value.
+	isPointer          bool
+	responseDescriptor protoreflect.MessageDescriptor
+	// pool holds reset request messages for reuse when PoolRequests is enabled.
+	pool *sync.Pool
+}
+
+// newMethodPlan checks the request parameter of an RPC method and returns its plan.
+// The request message can be passed to the method as a pointer or by value, interface parameters are not supported
+// because the type of the message to decode can't be determined.
+func newMethodPlan(methodName string, methodFunc reflect.Value) (*methodPlan, error) {
+	methodType := methodFunc.Type()
+	requestType := methodType.In(1)
+	plan := &methodPlan{methodFunc: methodFunc, messageType: requestType, isPointer: requestType.Kind() == reflect.Ptr}
+	if plan.isPointer {
+		plan.messageType = requestType.Elem()
+	}
+	if requestType.Kind() == reflect.Interface || !reflect.PtrTo(plan.messageType).Implements(protoMessageType) {
+		return nil, fmt.Errorf("grpcj: request parameter of method %s must be a proto message or a pointer to one, got %s", methodName, requestType)
+	}
+	plan.responseDescriptor = messageDescriptor(methodType.Out(0))
+	return plan, nil
+}
+
+// newRequest creates the request argument of the method along with the message to decode the request into.
+func (p *methodPlan) newRequest() (reflect.Value, proto.Message) {
+	var msg reflect.Value
+	if p.pool != nil {
+		msg = reflect.ValueOf(p.pool.Get())
+	} else {
+		msg = reflect.New(p.messageType)
+	}
+	if p.isPointer {
+		return msg, msg.Interface().(proto.Message)
+	}
+	// The message is decoded through the pointer, the value is copied when the method is called.
+	return msg.Elem(), msg.Interface().(proto.Message)
+}
+
+// release resets the request message and returns it to the pool once the response has been written.
+func (p *methodPlan) release(msg proto.Message) {
+	if p.pool == nil {
+		return
+	}
+	msg.Reset()
+	p.pool.Put(msg)
+}
+
+// call calls the method with requestArg, or with req when an interceptor replaced the request message.
+func (p *methodPlan) call(ctx context.Context, requestArg reflect.Value, requestMsg, req interface{}) (interface{}, error) {
+	arg := requestArg
+	if req != requestMsg {
+		if arg = reflect.ValueOf(req); !p.isPointer {
+			arg = arg.Elem()
+		}
+	}
+	methodReturnVals := p.methodFunc.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	err, _ := methodReturnVals[1].Interface().(error)
+	return methodReturnVals[0].Interface(), err
 }
 
-func grpcjHandler(methodFunc reflect.Value, httpServerOpts *serverOpts) http.HandlerFunc {
+func grpcjHandler(grpcServer interface{}, endpoint, methodName string, methodFunc reflect.Value, httpServerOpts *serverOpts) (http.HandlerFunc, error) {
+	plan, err := newMethodPlan(methodName, methodFunc)
+	if err != nil {
+		return nil, err
+	}
+	if httpServerOpts.poolRequests {
+		plan.pool = &sync.Pool{New: func() interface{} {
+			return reflect.New(plan.messageType).Interface()
+		}}
+	}
+	interceptor := chainUnaryInterceptors(httpServerOpts.unaryInterceptors)
+	info := &grpc.UnaryServerInfo{Server: grpcServer, FullMethod: httpServerOpts.fullMethodName(grpcServer, methodName)}
+	timeout := httpServerOpts.methodTimeout(methodName)
+	pathParams := pathParamNames(endpoint)
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ctx, cancel := context.WithTimeout(context.Background(), httpServerOpts.timeout)
+		timeout, err := httpServerOpts.requestTimeout(r, timeout)
+		if err != nil {
+			writeJSONStatus(w, status.New(codes.InvalidArgument, err.Error()), httpServerOpts)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
 		defer cancel()
+		ctx = incomingContext(ctx, r, httpServerOpts)
+		stream := &serverTransportStream{method: endpoint}
+		ctx = grpc.NewContextWithServerTransportStream(ctx, stream)
 
-		structType := methodFunc.Type().In(1).Elem()
-		structInstance, _ := reflect.New(structType).Interface().(proto.Message)
+		requestArg, requestMsg := plan.newRequest()
+		defer plan.release(requestMsg)
+		marshaler := httpServerOpts.requestMarshaler(r)
 
-		switch r.Method {
-		case "POST":
-			defer r.Body.Close()
-			if err := httpServerOpts.unmarshaler.Unmarshal(r.Body, structInstance); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-		case "GET":
-			parsedJSON, err := qson.ToJSON(r.URL.RawQuery)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+		// HEAD requests are served like GET requests, without the body of the response.
+		httpMethod := r.Method
+		if httpMethod == http.MethodHead {
+			httpMethod = http.MethodGet
+		}
+		if !httpServerOpts.isAllowedHTTPMethod(endpoint, httpMethod) {
+			writeMethodNotAllowed(w, endpoint, httpServerOpts)
+			return
+		}
+
+		var mask fieldMaskTree
+		if httpServerOpts.fieldMask {
+			var fields string
+			r, fields = takeFieldMask(r)
+			if mask, err = parseFieldMask(plan.responseDescriptor, fields); err != nil {
+				writeJSONStatus(w, status.New(codes.InvalidArgument, err.Error()), httpServerOpts)
 				return
 			}
-			if err := httpServerOpts.unmarshaler.Unmarshal(ioutil.NopCloser(bytes.NewReader(parsedJSON)), structInstance); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+
+		limitRequestBody(w, r, httpServerOpts)
+		if err := decodeRequest(r, requestMsg, httpServerOpts); err != nil {
+			writeDecodeError(w, err, httpServerOpts)
+			return
+		}
+		if len(pathParams) > 0 {
+			if err := setPathParams(r, requestMsg, pathParams); err != nil {
+				writeDecodeError(w, err, httpServerOpts)
 				return
 			}
-		default:
-			w.WriteHeader(http.StatusNotImplemented)
+		}
+		if err := httpServerOpts.validate(ctx, methodName, requestMsg); err != nil {
+			writeJSONStatus(w, status.Convert(err), httpServerOpts)
 			return
 		}
 
-		methodArgs := []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(structInstance)}
-		methodReturnVals := methodFunc.Call(methodArgs)
+		call := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return plan.call(ctx, requestArg, requestMsg, req)
+		}
+		var span trace.Span
+		if httpServerOpts.tracer != nil {
+			ctx, span = httpServerOpts.startSpan(ctx, r, info.FullMethod)
+		}
+		start := time.Now()
+		var result interface{}
+		if interceptor != nil {
+			result, err = interceptor(ctx, requestMsg, info, call)
+		} else {
+			result, err = call(ctx, requestMsg)
+		}
+		duration := time.Since(start)
+		if err == nil && isNilMessage(result) {
+			err = status.Errorf(codes.Internal, "method %s returned a nil response without an error", methodName)
+		}
+		if httpServerOpts.metadataToHeader != nil {
+			stream.writeHeaders(w.Header(), httpServerOpts.metadataToHeader)
+		}
 
 		// If we got back an error then return it
-		err, _ := methodReturnVals[1].Interface().(error)
+		statusErr := contextError(err)
+		if span != nil {
+			endSpan(span, statusErr)
+		}
+		httpServerOpts.metrics.observeRPC(methodName, status.Code(statusErr), duration)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(ctx, w, r, err, httpServerOpts)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		resp, _ := methodReturnVals[0].Interface().(proto.Message)
-		if err := httpServerOpts.marshaler.Marshal(w, resp); err != nil {
-			http.Error(w, "An error has occured", http.StatusInternalServerError)
+		resp, _ := result.(proto.Message)
+		resp = applyFieldMask(resp, mask)
+		writeResponse(w, r, resp, marshaler, methodName, httpServerOpts)
+	})
+	return handler, nil
+}
+
+// isNilMessage reports whether the response of a method is nil, either a nil interface or a nil message pointer.
+// Like gRPC, a method that returns a nil response without an error fails with an Internal status instead of writing an empty response.
+func isNilMessage(msg interface{}) bool {
+	if msg == nil {
+		return true
+	}
+	v := reflect.ValueOf(msg)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// writeResponse writes the response message of methodName, in the protobuf wire format when the client accepts it and as JSON otherwise.
+func writeResponse(w http.ResponseWriter, r *http.Request, resp proto.Message, marshaler JSONPBMarshaler, methodName string, httpServerOpts *serverOpts) {
+	if httpServerOpts.emptyNoContent && proto.MessageName(resp) == "google.protobuf.Empty" {
+		if _, ok := httpServerOpts.successStatuses[methodName]; ok {
+			w.WriteHeader(httpServerOpts.successStatus(methodName))
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	if contentType := protobufResponseContentType(r); contentType != "" {
+		body, err := marshalProtobuf(resp)
+		if err != nil {
+			httpServerOpts.logger.Errorf("Error marshaling the response of %s: %v", methodName, err)
+			writeJSONStatus(w, status.New(codes.Internal, "An error has occured"), httpServerOpts)
 			return
 		}
-	})
-	return handler
+		writeBody(w, r, contentType, body, methodName, httpServerOpts)
+		return
+	}
+
+	// The response is marshaled into a buffer so a marshal error can still be reported with a proper status
+	// instead of a truncated body.
+	body := getBuffer()
+	defer putBuffer(body)
+	if err := marshalerFor(marshaler, resp).Marshal(body, resp); err != nil {
+		httpServerOpts.logger.Errorf("Error marshaling the response of %s: %v", methodName, err)
+		writeJSONStatus(w, status.New(codes.Internal, "An error has occured"), httpServerOpts)
+		return
+	}
+	writeBody(w, r, httpServerOpts.contentType, body.Bytes(), methodName, httpServerOpts)
+}
+
+// maxPooledBufferSize is the capacity above which buffers are not returned to bufferPool,
+// so that a few large responses don't keep their memory in use.
+const maxPooledBufferSize = 64 << 10
+
+// bufferPool holds the buffers that responses are marshaled into before they are written.
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// getBuffer returns an empty buffer from bufferPool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns buf to bufferPool once its content has been written, it must not be used afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
 }
@@ -0,0 +1,796 @@
+package grpcj
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zang-cloud/grpc-json/jsonpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+)
+
+func TestHTTPMethods(t *testing.T) {
+	restricted := AllowedHTTPMethods(map[string][]string{"/Add": {"put", "DELETE"}})
+	tests := []struct {
+		method     string
+		target     string
+		options    []func(*serverOpts)
+		wantStatus int
+		wantBody   string
+		wantAllow  string
+	}{
+		{"POST", "/Add", nil, http.StatusOK, `{"sum":3}`, ""},
+		{"GET", "/Add?num_one=1&num_two=2", nil, http.StatusOK, `{"sum":3}`, ""},
+		{"PUT", "/Add", nil, http.StatusMethodNotAllowed, "", "POST, GET"},
+		{"DELETE", "/Add?num_one=1&num_two=2", nil, http.StatusMethodNotAllowed, "", "POST, GET"},
+		{"PUT", "/Add", []func(*serverOpts){restricted}, http.StatusOK, `{"sum":3}`, ""},
+		{"DELETE", "/Add?num_one=1&num_two=2", []func(*serverOpts){restricted}, http.StatusOK, `{"sum":3}`, ""},
+		{"POST", "/Add", []func(*serverOpts){restricted}, http.StatusMethodNotAllowed, "", "PUT, DELETE"},
+		{"HEAD", "/Add?num_one=1&num_two=2", nil, http.StatusOK, "", ""},
+		{"HEAD", "/Add?num_one=1&num_two=2", []func(*serverOpts){restricted}, http.StatusMethodNotAllowed, "", "PUT, DELETE"},
+		{"PATCH", "/Add", []func(*serverOpts){AllowedHTTPMethods(map[string][]string{"/Add": {"PATCH"}})}, http.StatusOK, `{"sum":3}`, ""},
+		{"TRACE", "/Add", []func(*serverOpts){AllowedHTTPMethods(map[string][]string{"/Add": {"POST", "TRACE"}})}, http.StatusMethodNotAllowed, "", "POST"},
+	}
+	for _, test := range tests {
+		req := newJSONRequest(test.method, test.target, `{"num_one": 1, "num_two": 2}`)
+		rec := serveMethod(&testServer{}, "Add", req, test.options...)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s %s: Expect status: %d, Got: %d", test.method, test.target, test.wantStatus, rec.Code)
+		}
+		if rec.Body.String() != test.wantBody {
+			t.Errorf("%s %s: Expect: %s, Got: %s", test.method, test.target, test.wantBody, rec.Body.String())
+		}
+		if allow := rec.Header().Get("Allow"); allow != test.wantAllow {
+			t.Errorf("%s %s: Expect Allow: %q, Got: %q", test.method, test.target, test.wantAllow, allow)
+		}
+	}
+}
+
+func TestHeadRequest(t *testing.T) {
+	rec := serveMethod(&testServer{}, "Add", newJSONRequest("HEAD", "/Add?num_one=1&num_two=2", ""), MethodETag((&testServer{}).Add))
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("Expect a 200 without a body, Got: %d %s", rec.Code, rec.Body.String())
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != defaultContentType {
+		t.Errorf("Expect Content-Type: %s, Got: %s", defaultContentType, contentType)
+	}
+	// The length of {"sum":3}.
+	if length := rec.Header().Get("Content-Length"); length != "9" {
+		t.Errorf("Expect Content-Length: 9, Got: %q", length)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Errorf("Expect the ETag of the GET response")
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	if _, err := NewServer(nil); err == nil {
+		t.Error("Expect an error for a nil grpcServer")
+	}
+
+	serverHTTP, err := NewServer(&testServer{}, Port(":9090"))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	if serverHTTP.Addr != ":9090" {
+		t.Errorf("Expect: :9090, Got: %s", serverHTTP.Addr)
+	}
+
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"sum":3}` {
+		t.Errorf("Expect: 200 {\"sum\":3}, Got: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHTTPServerConfig(t *testing.T) {
+	serverHTTP, err := NewServer(&testServer{})
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	if serverHTTP.ReadHeaderTimeout != defaultReadHeaderTimeout || serverHTTP.ReadTimeout != defaultReadTimeout ||
+		serverHTTP.IdleTimeout != defaultIdleTimeout || serverHTTP.WriteTimeout != 0 {
+		t.Errorf("Expect the default timeouts, Got: header %s, read %s, idle %s, write %s",
+			serverHTTP.ReadHeaderTimeout, serverHTTP.ReadTimeout, serverHTTP.IdleTimeout, serverHTTP.WriteTimeout)
+	}
+
+	serverHTTP, err = NewServer(&testServer{}, HTTPServerConfig(func(s *http.Server) {
+		s.WriteTimeout = time.Minute
+		s.IdleTimeout = 0
+	}))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	if serverHTTP.WriteTimeout != time.Minute || serverHTTP.IdleTimeout != 0 || serverHTTP.ReadHeaderTimeout != defaultReadHeaderTimeout {
+		t.Errorf("Expect the configured timeouts, Got: header %s, idle %s, write %s", serverHTTP.ReadHeaderTimeout, serverHTTP.IdleTimeout, serverHTTP.WriteTimeout)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	if _, err := Handler(nil); err == nil {
+		t.Error("Expect an error for a nil grpcServer")
+	}
+
+	handler, err := Handler(&testServer{}, PathPrefix("/api"), HealthCheck("/health", func() error { return nil }, time.Minute))
+	if err != nil {
+		t.Fatalf("Error to create the handler, Error:%s", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api/", handler)
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other"))
+	})
+
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"POST", "/api/Add", `{"num_one": 1, "num_two": 2}`, http.StatusOK, `{"sum":3}`},
+		{"GET", "/api/health", "", http.StatusOK, `{"status":"HEALTHY"}`},
+		{"GET", "/other", "", http.StatusOK, "other"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, newJSONRequest(test.method, test.path, test.body))
+		if rec.Code != test.wantCode || rec.Body.String() != test.wantBody {
+			t.Errorf("%s %s: Expect: %d %s, Got: %d %s", test.method, test.path, test.wantCode, test.wantBody, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestServeListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error to listen, Error:%s", err)
+	}
+	go Serve(&testServer{}, Port(":1"), Listener(listener))
+
+	resp, err := http.Post("http://"+listener.Addr().String()+"/Add", "application/json", strings.NewReader(`{"num_one": 1, "num_two": 2}`))
+	if err != nil {
+		t.Fatalf("Error to POST to the listener, Error:%s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != `{"sum":3}` {
+		t.Errorf("Expect: 200 {\"sum\":3}, Got: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestMethodTimeout(t *testing.T) {
+	server := &testServer{}
+	tests := []struct {
+		options     []func(*serverOpts)
+		wantTimeout time.Duration
+	}{
+		{nil, defaultTimeout},
+		{[]func(*serverOpts){Timeout(5 * time.Second)}, 5 * time.Second},
+		{[]func(*serverOpts){Timeout(5 * time.Second), MethodTimeout(server.Add, 90*time.Second)}, 90 * time.Second},
+		{[]func(*serverOpts){MethodTimeout((*testServer).Add, time.Minute)}, time.Minute},
+		{[]func(*serverOpts){MethodTimeout(server.Count, time.Minute)}, defaultTimeout},
+	}
+	for _, test := range tests {
+		start := time.Now()
+		serveMethod(server, "Add", newJSONRequest("POST", "/Add", `{}`), test.options...)
+		deadline, ok := server.ctx.Deadline()
+		if !ok {
+			t.Fatal("Expect a deadline on the context")
+		}
+		if got := deadline.Sub(start); got < test.wantTimeout || got > test.wantTimeout+time.Second {
+			t.Errorf("Expect timeout: %s, Got: %s", test.wantTimeout, got)
+		}
+	}
+}
+
+func TestMethodSuccessStatus(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+	}{
+		{nil, http.StatusCreated},
+		{status.Error(codes.AlreadyExists, "exists"), http.StatusConflict},
+	}
+	for _, test := range tests {
+		server := &testServer{err: test.err}
+		rec := serveMethod(server, "Add", newJSONRequest("POST", "/Add", `{"num_one": 1}`), MethodSuccessStatus(server.Add, http.StatusCreated))
+		if rec.Code != test.wantStatus {
+			t.Errorf("Expect status: %d, Got: %d", test.wantStatus, rec.Code)
+		}
+	}
+
+	rec := serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": 1}`), MethodSuccessStatus((*testServer).Count, http.StatusAccepted))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expect status: %d, Got: %d", http.StatusOK, rec.Code)
+	}
+}
+
+type deleteServer struct{}
+
+func (s *deleteServer) Delete(ctx context.Context, req *addRequest) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, nil
+}
+
+func TestEmptyNoContent(t *testing.T) {
+	server := &deleteServer{}
+	tests := []struct {
+		options    []func(*serverOpts)
+		wantStatus int
+		wantBody   string
+	}{
+		{nil, http.StatusOK, "{}"},
+		{[]func(*serverOpts){EmptyNoContent(true)}, http.StatusNoContent, ""},
+		{[]func(*serverOpts){EmptyNoContent(true), MethodSuccessStatus(server.Delete, http.StatusAccepted)}, http.StatusAccepted, ""},
+	}
+	for _, test := range tests {
+		rec := serveMethod(server, "Delete", newJSONRequest("POST", "/Delete", `{}`), test.options...)
+		if rec.Code != test.wantStatus || rec.Body.String() != test.wantBody {
+			t.Errorf("Expect: %d %q, Got: %d %q", test.wantStatus, test.wantBody, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestPoolRequests(t *testing.T) {
+	server := &testServer{}
+	handler, err := grpcjHandler(server, "/Add", "Add", reflect.ValueOf(server).MethodByName("Add"), applyOptions([]func(*serverOpts){PoolRequests(true)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fields set by a request must not leak into the next one that reuses its message.
+	for _, test := range []struct{ body, want string }{
+		{`{"num_one": 1, "num_two": 2}`, `{"sum":3}`},
+		{`{"num_one": 5}`, `{"sum":5}`},
+		{`{}`, `{"sum":0}`},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", test.body))
+		if rec.Body.String() != test.want {
+			t.Errorf("%s: Expect: %s, Got: %s", test.body, test.want, rec.Body.String())
+		}
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	server := &testServer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	serveMethod(server, "Add", newJSONRequest("POST", "/Add", `{}`).WithContext(ctx))
+	if server.ctx.Err() != context.Canceled {
+		t.Errorf("Expect the method context to be cancelled with the request, Got: %v", server.ctx.Err())
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	// Borrow the certificate of an httptest TLS server, its client trusts it.
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error to listen, Error:%s", err)
+	}
+	go Serve(&testServer{}, Listener(listener), TLSConfig(&tls.Config{Certificates: tlsServer.TLS.Certificates}))
+
+	resp, err := tlsServer.Client().Post("https://"+listener.Addr().String()+"/Add", "application/json", strings.NewReader(`{"num_one": 1, "num_two": 2}`))
+	if err != nil {
+		t.Fatalf("Error to POST to the listener, Error:%s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.TLS == nil || resp.StatusCode != http.StatusOK || string(body) != `{"sum":3}` {
+		t.Errorf("Expect: 200 {\"sum\":3} over TLS, Got: %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestMaxRequestBodySize(t *testing.T) {
+	body := `{"num_one": 1, "num_two": 2}`
+	tests := []struct {
+		method     string
+		options    []func(*serverOpts)
+		wantStatus int
+	}{
+		{"POST", nil, http.StatusOK},
+		{"POST", []func(*serverOpts){MaxRequestBodySize(10)}, http.StatusRequestEntityTooLarge},
+		{"POST", []func(*serverOpts){MaxRequestBodySize(int64(len(body)))}, http.StatusOK},
+		{"POST", []func(*serverOpts){MaxRequestBodySize(0)}, http.StatusOK},
+		{"GET", []func(*serverOpts){MaxRequestBodySize(10)}, http.StatusOK},
+	}
+	for _, test := range tests {
+		rec := serveMethod(&testServer{}, "Add", newJSONRequest(test.method, "/Add?num_one=1&num_two=2", body), test.options...)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d %s", test.method, test.wantStatus, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	server := &testServer{}
+	for _, prefix := range []string{"/api/v1", "/api/v1/", "api/v1"} {
+		serverHTTP, err := NewServer(server, PathPrefix(prefix),
+			AddEndpoints(map[string]interface{}{"/Sum": server.Add}),
+			HealthCheck("/health", func() error { return nil }, time.Hour),
+		)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		tests := []struct {
+			path       string
+			wantStatus int
+		}{
+			{"/api/v1/Add", http.StatusOK},
+			{"/api/v1/Sum", http.StatusOK},
+			{"/api/v1/health", http.StatusOK},
+			{"/Add", http.StatusNotFound},
+		}
+		for _, test := range tests {
+			rec := httptest.NewRecorder()
+			serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, `{"num_one": 1, "num_two": 2}`))
+			if rec.Code != test.wantStatus {
+				t.Errorf("PathPrefix(%q) %s: Expect status: %d, Got: %d", prefix, test.path, test.wantStatus, rec.Code)
+			}
+		}
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	server := &testServer{}
+	serverHTTP, err := NewServer(server, AddEndpoints(map[string]interface{}{"/Sum/{num_one}/{numTwo}": server.Add}))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/Sum/1/2", `{}`, http.StatusOK, `{"sum":3}`},
+		{"/Sum/1/2", `{"num_one": 5}`, http.StatusOK, `{"sum":3}`},
+		{"/Sum/1/two", `{}`, http.StatusBadRequest, ""},
+		{"/Sum/1", `{}`, http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, test.body))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.path, test.wantStatus, rec.Code)
+		}
+		if test.wantBody != "" && strings.TrimSpace(rec.Body.String()) != test.wantBody {
+			t.Errorf("%s: Expect body: %s, Got: %s", test.path, test.wantBody, rec.Body.String())
+		}
+	}
+
+	if _, err := NewServer(server, AddEndpoints(map[string]interface{}{"/Sum/{id}": server.Add})); err == nil {
+		t.Error("Expect an error for a path parameter that is not a field of the request")
+	}
+}
+
+func TestMergeQueryParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []func(*serverOpts)
+		path    string
+		body    string
+		want    string
+	}{
+		{"disabled", nil, "/Add?num_two=10", `{"num_one": 1, "num_two": 2}`, `{"sum":3}`},
+		{"query only", []func(*serverOpts){MergeQueryParams(true)}, "/Add?num_two=10", `{"num_one": 1}`, `{"sum":11}`},
+		{"query overrides body", []func(*serverOpts){MergeQueryParams(true)}, "/Add?num_two=10", `{"num_one": 1, "num_two": 2}`, `{"sum":11}`},
+		{"body overrides query", []func(*serverOpts){MergeQueryParams(true), QueryParamsPrecedence(BodyOverridesQuery)}, "/Add?num_one=5&num_two=10", `{"num_one": 1}`, `{"sum":11}`},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("%s: Error to create the server, Error:%s", test.name, err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, test.body))
+		if got := strings.TrimSpace(rec.Body.String()); got != test.want {
+			t.Errorf("%s: Expect body: %s, Got: %s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestClientInt64AsString(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		method  string
+		path    string
+		header  string
+		want    string
+	}{
+		{"default", true, "POST", "/Add", "", `{"sum":3}`},
+		{"query", true, "POST", "/Add?int64_as_string=true", "", `{"sum":"3"}`},
+		{"header", true, "POST", "/Add", "true", `{"sum":"3"}`},
+		{"query wins", true, "POST", "/Add?int64_as_string=false", "true", `{"sum":3}`},
+		{"GET", true, "GET", "/Add?num_one=1&num_two=2&int64_as_string=1", "", `{"sum":"3"}`},
+		{"disabled", false, "POST", "/Add", "true", `{"sum":3}`},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, ClientInt64AsString(test.enabled), AllowedHTTPMethods(map[string][]string{"/Add": {"GET", "POST"}}))
+		if err != nil {
+			t.Fatalf("%s: Error to create the server, Error:%s", test.name, err)
+		}
+		req := newJSONRequest(test.method, test.path, `{"num_one": 1, "num_two": 2}`)
+		if test.header != "" {
+			req.Header.Set("X-Int64-As-String", test.header)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if got := strings.TrimSpace(rec.Body.String()); got != test.want {
+			t.Errorf("%s: Expect body: %s, Got: %s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestAllowUnknownFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    []func(*serverOpts)
+		wantStatus int
+	}{
+		{"default", nil, http.StatusBadRequest},
+		{"allowed", []func(*serverOpts){AllowUnknownFields(true)}, http.StatusOK},
+		{"after Unmarshaler", []func(*serverOpts){Unmarshaler(&jsonpb.Unmarshaler{}), AllowUnknownFields(true)}, http.StatusOK},
+		{"before Unmarshaler", []func(*serverOpts){AllowUnknownFields(true), Unmarshaler(&jsonpb.Unmarshaler{})}, http.StatusBadRequest},
+		{"GOGO", []func(*serverOpts){Unmarshaler(DefaultUnmarshalerGOGO), AllowUnknownFields(true)}, http.StatusOK},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&testServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("%s: Error to create the server, Error:%s", test.name, err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2, "num_three": 3}`))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.name, test.wantStatus, rec.Code)
+		}
+	}
+	if DefaultUnmarshaler.AllowUnknownFields {
+		t.Error("Expect AllowUnknownFields not to modify DefaultUnmarshaler")
+	}
+}
+
+func TestMethodFromContext(t *testing.T) {
+	var middlewareMethod, rpcMethod string
+	middleware := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			middlewareMethod, _ = MethodFromContext(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	}
+	server := &testServer{}
+	serverHTTP, err := NewServer(server, Middleware(middleware), EndpointNamer(SnakeCaseNamer), PathPrefix("/api"))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/api/add", `{"num_one": 1, "num_two": 2}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expect status: %d, Got: %d", http.StatusOK, rec.Code)
+	}
+	rpcMethod, _ = MethodFromContext(server.ctx)
+	if middlewareMethod != "Add" || rpcMethod != "Add" {
+		t.Errorf("Expect the method name Add in middleware and RPC method, Got: %q and %q", middlewareMethod, rpcMethod)
+	}
+	if _, ok := MethodFromContext(context.Background()); ok {
+		t.Error("Expect no method name on a context that didn't come from a request")
+	}
+}
+
+func TestSnakeCaseNamer(t *testing.T) {
+	tests := map[string]string{
+		"Add":            "add",
+		"GetUserProfile": "get_user_profile",
+		"GetHTTPStatus":  "get_http_status",
+		"ListV2Users":    "list_v2_users",
+		"HTTPGet":        "http_get",
+	}
+	for methodName, want := range tests {
+		if got := SnakeCaseNamer(methodName); got != want {
+			t.Errorf("%s: Expect: %s, Got: %s", methodName, want, got)
+		}
+	}
+}
+
+func TestEndpointNamer(t *testing.T) {
+	server := &testServer{}
+	tests := []struct {
+		namer    func(string) string
+		path     string
+		wantCode int
+	}{
+		{IdentityNamer, "/Add", http.StatusOK},
+		{LowerNamer, "/add", http.StatusOK},
+		{LowerNamer, "/Add", http.StatusNotFound},
+		{SnakeCaseNamer, "/count", http.StatusOK},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(server, ServiceDesc(&testServiceDesc), EndpointNamer(test.namer))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, `{"num_one": 1, "num_two": 2}`))
+		if rec.Code != test.wantCode {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.path, test.wantCode, rec.Code)
+		}
+	}
+}
+
+type valueServer struct{}
+
+func (valueServer) Add(ctx context.Context, req addRequest) (*addResponse, error) {
+	return &addResponse{Sum: req.NumOne + req.NumTwo}, nil
+}
+
+type interfaceServer struct{}
+
+func (interfaceServer) Add(ctx context.Context, req proto.Message) (*addResponse, error) {
+	return &addResponse{}, nil
+}
+
+type nonMessageServer struct{}
+
+func (nonMessageServer) Add(ctx context.Context, req *http.Request) (*addResponse, error) {
+	return &addResponse{}, nil
+}
+
+func TestRequestParameterTypes(t *testing.T) {
+	serverHTTP, err := NewServer(valueServer{})
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"sum":3}` {
+		t.Errorf("Expect a request passed by value to be decoded, Got: %d %s", rec.Code, rec.Body.String())
+	}
+
+	for _, server := range []interface{}{interfaceServer{}, nonMessageServer{}} {
+		if _, err := NewServer(server); err == nil {
+			t.Errorf("%T: Expect an error for an unsupported request parameter", server)
+		}
+	}
+}
+
+type helperServer struct {
+	testServer
+}
+
+func (s *helperServer) Close() error { return nil }
+
+func (s *helperServer) Name() string { return "helper" }
+
+func (s *helperServer) Sum(ctx context.Context, req *addRequest) int64 {
+	return req.NumOne + req.NumTwo
+}
+
+func TestSkipNonRPCMethods(t *testing.T) {
+	server := &helperServer{}
+	serverHTTP, err := NewServer(server)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/Add", http.StatusOK},
+		{"/Close", http.StatusNotFound},
+		{"/Name", http.StatusNotFound},
+		{"/Sum", http.StatusNotFound},
+		// Count is a streaming method and no ServiceDesc was given.
+		{"/Count", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, `{"num_one": 1, "num_two": 2}`))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.path, test.wantStatus, rec.Code)
+		}
+	}
+
+	if _, err := NewServer(server, AddEndpoints(map[string]interface{}{"/Close": server.Close})); err == nil {
+		t.Error("Expect an error when adding an endpoint for a method that is not an RPC method")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	inHandler, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	block := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(inHandler)
+			<-release
+			next.ServeHTTP(w, r)
+		})
+	}
+	serverHTTP, err := NewServer(&testServer{}, Middleware(block))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error to listen, Error:%s", err)
+	}
+	go serverHTTP.Serve(listener)
+	go http.Post("http://"+listener.Addr().String()+"/Add", "application/json", strings.NewReader(`{}`))
+	<-inHandler
+
+	start := time.Now()
+	if shutdown(serverHTTP, 50*time.Millisecond, defaultLogger()) {
+		t.Error("Expect the shutdown to be forced while a request is in flight")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expect the shutdown to be forced after the timeout, Got: %s", elapsed)
+	}
+}
+
+func TestDisableSignalHandling(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error to listen, Error:%s", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		Serve(&testServer{}, Listener(listener), DisableSignalHandling())
+		close(done)
+	}()
+
+	resp, err := http.Post("http://"+listener.Addr().String()+"/Add", "application/json", strings.NewReader(`{"num_one": 1, "num_two": 2}`))
+	if err != nil {
+		t.Fatalf("Error to POST to the listener, Error:%s", err)
+	}
+	resp.Body.Close()
+
+	listener.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expect Serve to return once the listener is closed")
+	}
+}
+
+func TestMethodMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name+" "+r.URL.Path)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	server := &testServer{}
+	serverHTTP, err := NewServer(server, ServiceDesc(&testServiceDesc),
+		Middleware(record("global")),
+		MethodMiddleware(server.Add, record("add1"), record("add2")),
+		AddEndpoints(map[string]interface{}{"/Sum": server.Add}),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	for _, path := range []string{"/Add", "/Count", "/Sum"} {
+		serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), newJSONRequest("POST", path, `{"num_one": 1}`))
+	}
+
+	want := []string{"global /Add", "add1 /Add", "add2 /Add", "global /Count", "global /Sum", "add1 /Sum", "add2 /Sum"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expect: %v, Got: %v", want, calls)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) MiddlewareFunc {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, "before "+name)
+				next.ServeHTTP(w, r)
+				calls = append(calls, "after "+name)
+			})
+		}
+	}
+	tests := []struct {
+		options []func(*serverOpts)
+		want    []string
+	}{
+		{
+			[]func(*serverOpts){Middleware(record("a"), record("b"), record("c"))},
+			[]string{"before a", "before b", "before c", "after c", "after b", "after a"},
+		},
+		{
+			[]func(*serverOpts){Middleware(record("a")), Middleware(record("b"), record("c")), Middleware(record("d"))},
+			[]string{"before a", "before b", "before c", "before d", "after d", "after c", "after b", "after a"},
+		},
+		{
+			[]func(*serverOpts){MethodMiddleware((*testServer).Add, record("m1"), record("m2")), Middleware(record("a"), record("b"))},
+			[]string{"before a", "before b", "before m1", "before m2", "after m2", "after m1", "after b", "after a"},
+		},
+	}
+	for _, test := range tests {
+		calls = nil
+		serverHTTP, err := NewServer(&testServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), newJSONRequest("POST", "/Add", `{}`))
+		if strings.Join(calls, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("Expect: %v, Got: %v", test.want, calls)
+		}
+	}
+}
+
+type echoServer struct{}
+
+func (echoServer) Echo(ctx context.Context, req *sourcecontextpb.SourceContext) (*sourcecontextpb.SourceContext, error) {
+	return req, nil
+}
+
+func TestUTF8RoundTrip(t *testing.T) {
+	for _, name := range []string{"héllo wörld", "日本語のテキスト", "emoji 🚀🎉", "mixed Ωμέγα 中文 👍"} {
+		body := `{"file_name": "` + name + `"}`
+		rec := serveMethod(echoServer{}, "Echo", newJSONRequest("POST", "/Echo", body))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: Expect status: 200, Got: %d %s", name, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+			t.Errorf("%s: Expect Content-Type: application/json; charset=utf-8, Got: %s", name, got)
+		}
+		if !utf8.Valid(rec.Body.Bytes()) {
+			t.Errorf("%s: Expect a valid UTF-8 body, Got: %q", name, rec.Body.String())
+		}
+		var resp sourcecontextpb.SourceContext
+		if err := DefaultUnmarshaler.Unmarshal(rec.Body, &resp); err != nil {
+			t.Fatalf("%s: Error to Unmarshal the response, Error:%s", name, err)
+		}
+		if resp.FileName != name {
+			t.Errorf("Expect: %q, Got: %q", name, resp.FileName)
+		}
+	}
+}
+
+func TestOptionsRequest(t *testing.T) {
+	server := &testServer{}
+	serverHTTP, err := NewServer(server,
+		ServiceDesc(&testServiceDesc),
+		AllowedHTTPMethods(map[string][]string{"/Sum": {"PUT", "DELETE"}}),
+		AddEndpoints(map[string]interface{}{"/Sum": server.Add}),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := map[string]string{
+		"/Add":   "POST, GET, HEAD, OPTIONS",
+		"/Sum":   "PUT, DELETE, OPTIONS",
+		"/Count": "POST, GET, OPTIONS",
+	}
+	for path, wantAllow := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("OPTIONS", path, `{"num_one": "invalid"}`))
+		if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+			t.Errorf("%s: Expect a 204 without a body, Got: %d %s", path, rec.Code, rec.Body.String())
+		}
+		if allow := rec.Header().Get("Allow"); allow != wantAllow {
+			t.Errorf("%s: Expect Allow: %q, Got: %q", path, wantAllow, allow)
+		}
+	}
+	if server.ctx != nil {
+		t.Errorf("Expect the method not to be called")
+	}
+}
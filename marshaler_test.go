@@ -0,0 +1,76 @@
+package grpcj
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+	"github.com/zang-cloud/grpc-json/jsonpb"
+)
+
+func TestMarshalerWith(t *testing.T) {
+	base := jsonpb.Marshaler{EnumsAsInts: true, OrigName: true}
+	pretty := base.With(jsonpb.WithIndent("  "), jsonpb.WithInt64AsString(true), jsonpb.WithTimestampFormat(jsonpb.TimestampUnixMillis))
+	if base.Indent != "" || base.Int64AsString || base.TimestampFormat != jsonpb.TimestampRFC3339Nano {
+		t.Errorf("Expect the original marshaler to be unchanged, Got: %+v", base)
+	}
+	if pretty.Indent != "  " || !pretty.Int64AsString || pretty.TimestampFormat != jsonpb.TimestampUnixMillis || !pretty.EnumsAsInts || !pretty.OrigName {
+		t.Errorf("Expect the options to be applied to the copy, Got: %+v", pretty)
+	}
+
+	gogo := jsonpb.MarshalerGOGO{HandleStdTime: true}
+	if got := gogo.With(jsonpb.WithEmitDefaults(true)); !got.EmitDefaults || !got.HandleStdTime || gogo.EmitDefaults {
+		t.Errorf("Expect a modified copy of the GOGO marshaler, Got: %+v from %+v", got, gogo)
+	}
+}
+
+func TestMarshalerWithConcurrent(t *testing.T) {
+	shared := &jsonpb.Marshaler{OrigName: true}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(asString bool) {
+			defer wg.Done()
+			marshaler := shared.With(jsonpb.WithInt64AsString(asString))
+			var buf bytes.Buffer
+			if err := marshaler.Marshal(&buf, &addResponse{Sum: 3}); err != nil {
+				t.Errorf("Error to marshal, Error:%s", err)
+				return
+			}
+			want := `{"sum":3}`
+			if asString {
+				want = `{"sum":"3"}`
+			}
+			if buf.String() != want {
+				t.Errorf("Expect: %s, Got: %s", want, buf.String())
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+	if shared.Int64AsString {
+		t.Error("Expect the shared marshaler to be unchanged")
+	}
+}
+
+func TestRuntimeMarshaler(t *testing.T) {
+	gatewayMarshaler := &runtime.JSONPb{OrigName: true}
+	want, err := gatewayMarshaler.Marshal(&addResponse{Sum: 3})
+	if err != nil {
+		t.Fatalf("Error to marshal, Error:%s", err)
+	}
+
+	// The Marshaler option is overridden regardless of the order.
+	options := []func(*serverOpts){RuntimeMarshaler(gatewayMarshaler), Marshaler(&jsonpb.Marshaler{})}
+	rec := serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": "1", "num_two": 2}`), options...)
+	if rec.Code != http.StatusOK || rec.Body.String() != string(want) {
+		t.Errorf("Expect the response of the gateway marshaler %s, Got: %d %s", want, rec.Code, rec.Body.String())
+	}
+
+	// Unlike the default jsonpb Unmarshaler, the gateway unmarshaler allows unknown fields.
+	rec = serveMethod(&testServer{}, "Add", newJSONRequest("POST", "/Add", `{"num_one": 1, "unknown": 1}`), options...)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"sum":"1"}` {
+		t.Errorf("Expect the request to be decoded by the gateway unmarshaler, Got: %d %s", rec.Code, rec.Body.String())
+	}
+}
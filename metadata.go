@@ -0,0 +1,130 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultHeaderToMetadata converts HTTP headers to gRPC metadata by lowercasing the header names and comma-joining multiple values.
+func DefaultHeaderToMetadata(header http.Header) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range header {
+		md.Set(strings.ToLower(name), strings.Join(values, ","))
+	}
+	return md
+}
+
+// HeaderToMetadata allows defining how the incoming HTTP headers are converted to the gRPC metadata
+// that is available to the RPC methods via metadata.FromIncomingContext. Default is DefaultHeaderToMetadata.
+// Passing nil disables the conversion.
+func HeaderToMetadata(headerToMetadata func(http.Header) metadata.MD) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.headerToMetadata = headerToMetadata
+	}
+}
+
+type httpRequestKey struct{}
+
+// HTTPRequestFromContext returns the HTTP request that an RPC method is serving, e.g. to read its remote address,
+// the TLS client certificates or headers that HeaderToMetadata doesn't convert. Methods that use it depend on the HTTP transport
+// and won't find a request when they are served by a gRPC server, prefer the incoming metadata when it is enough.
+// The request body has already been read when the method is called.
+func HTTPRequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(httpRequestKey{}).(*http.Request)
+	return r, ok
+}
+
+// incomingContext attaches the incoming metadata of r to ctx, along with r itself, the client as a peer.Peer
+// and the request ID set by the RequestID middleware.
+func incomingContext(ctx context.Context, r *http.Request, httpServerOpts *serverOpts) context.Context {
+	ctx = context.WithValue(ctx, httpRequestKey{}, r)
+	ctx = peerContext(ctx, r, httpServerOpts)
+	var md metadata.MD
+	if httpServerOpts.headerToMetadata != nil {
+		md = httpServerOpts.headerToMetadata(r.Header)
+	}
+	if requestID, ok := RequestIDFromContext(r.Context()); ok {
+		ctx = context.WithValue(ctx, requestIDKey{}, requestID)
+		if md == nil {
+			md = metadata.MD{}
+		}
+		md.Set(strings.ToLower(RequestIDHeader), requestID)
+	}
+	if md != nil {
+		ctx = metadata.NewIncomingContext(ctx, md)
+	}
+	return ctx
+}
+
+// DefaultMetadataToHeader emits every gRPC metadata key as an HTTP response header of the same name.
+func DefaultMetadataToHeader(key string) (string, bool) {
+	return key, true
+}
+
+// MetadataToHeader allows defining which header and trailer metadata set by the RPC methods (e.g. via grpc.SetHeader)
+// is written back as HTTP response headers. The function receives each metadata key and returns the header name to use
+// and whether the key should be emitted at all, which allows prefixing or filtering keys. Default is DefaultMetadataToHeader.
+// Passing nil disables writing metadata as response headers.
+//
+// For example, to only emit keys starting with "x-" under a "Grpc-Metadata-" prefix:
+//
+//	grpcj.MetadataToHeader(func(key string) (string, bool) {
+//		return "Grpc-Metadata-" + key, strings.HasPrefix(key, "x-")
+//	})
+func MetadataToHeader(metadataToHeader func(key string) (string, bool)) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.metadataToHeader = metadataToHeader
+	}
+}
+
+// serverTransportStream records the header and trailer metadata set by an RPC method so it can be written as HTTP headers.
+type serverTransportStream struct {
+	method  string
+	mu      sync.Mutex
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *serverTransportStream) Method() string {
+	return s.method
+}
+
+func (s *serverTransportStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// SendHeader records the header metadata, it is written to the HTTP response together with the response body.
+func (s *serverTransportStream) SendHeader(md metadata.MD) error {
+	return s.SetHeader(md)
+}
+
+func (s *serverTransportStream) SetTrailer(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// writeHeaders writes the recorded header and trailer metadata to the HTTP response headers.
+func (s *serverTransportStream) writeHeaders(header http.Header, metadataToHeader func(key string) (string, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, md := range []metadata.MD{s.header, s.trailer} {
+		for key, values := range md {
+			name, ok := metadataToHeader(key)
+			if !ok {
+				continue
+			}
+			for _, value := range values {
+				header.Add(name, value)
+			}
+		}
+	}
+}
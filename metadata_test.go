@@ -0,0 +1,95 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestHeaderToMetadata(t *testing.T) {
+	req := newJSONRequest("POST", "/Add", `{}`)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Add("X-Request-Id", "one")
+	req.Header.Add("X-Request-Id", "two")
+
+	server := &testServer{}
+	serveMethod(server, "Add", req)
+	md, ok := metadata.FromIncomingContext(server.ctx)
+	if !ok {
+		t.Fatal("Expect incoming metadata on the context")
+	}
+	if got := md.Get("authorization"); !reflect.DeepEqual(got, []string{"Bearer token"}) {
+		t.Errorf("Expect: [Bearer token], Got: %v", got)
+	}
+	if got := md.Get("x-request-id"); !reflect.DeepEqual(got, []string{"one,two"}) {
+		t.Errorf("Expect: [one,two], Got: %v", got)
+	}
+
+	custom := HeaderToMetadata(func(header http.Header) metadata.MD {
+		return metadata.Pairs("user", header.Get("X-User"))
+	})
+	req = newJSONRequest("POST", "/Add", `{}`)
+	req.Header.Set("X-User", "alice")
+	serveMethod(server, "Add", req, custom)
+	md, _ = metadata.FromIncomingContext(server.ctx)
+	if len(md) != 1 || md.Get("user")[0] != "alice" {
+		t.Errorf("Expect: map[user:[alice]], Got: %v", md)
+	}
+
+	serveMethod(server, "Add", newJSONRequest("POST", "/Add", `{}`), HeaderToMetadata(nil))
+	if _, ok := metadata.FromIncomingContext(server.ctx); ok {
+		t.Error("Expect no incoming metadata when HeaderToMetadata is nil")
+	}
+}
+
+type metadataServer struct{}
+
+func (s *metadataServer) Add(ctx context.Context, req *addRequest) (*addResponse, error) {
+	grpc.SetHeader(ctx, metadata.Pairs("x-next-cursor", "abc", "internal-key", "secret"))
+	grpc.SetTrailer(ctx, metadata.Pairs("x-ratelimit-remaining", "9"))
+	return &addResponse{}, nil
+}
+
+func TestHTTPRequestFromContext(t *testing.T) {
+	req := newJSONRequest("POST", "/Add", `{}`)
+	req.RemoteAddr = "192.0.2.1:1234"
+	server := &testServer{}
+	serveMethod(server, "Add", req)
+	r, ok := HTTPRequestFromContext(server.ctx)
+	if !ok || r.RemoteAddr != "192.0.2.1:1234" {
+		t.Errorf("Expect the HTTP request on the context, Got: %v", r)
+	}
+	if _, ok := HTTPRequestFromContext(context.Background()); ok {
+		t.Error("Expect no HTTP request on a context that doesn't come from grpcj")
+	}
+}
+
+func TestMetadataToHeader(t *testing.T) {
+	rec := serveMethod(&metadataServer{}, "Add", newJSONRequest("POST", "/Add", `{}`))
+	for name, want := range map[string]string{"X-Next-Cursor": "abc", "Internal-Key": "secret", "X-Ratelimit-Remaining": "9"} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s: Expect: %s, Got: %s", name, want, got)
+		}
+	}
+
+	filter := MetadataToHeader(func(key string) (string, bool) {
+		return "Grpc-Metadata-" + key, strings.HasPrefix(key, "x-")
+	})
+	rec = serveMethod(&metadataServer{}, "Add", newJSONRequest("POST", "/Add", `{}`), filter)
+	if got := rec.Header().Get("Grpc-Metadata-X-Next-Cursor"); got != "abc" {
+		t.Errorf("Expect: abc, Got: %s", got)
+	}
+	if got := rec.Header().Get("Grpc-Metadata-Internal-Key"); got != "" {
+		t.Errorf("Expect internal-key to be filtered, Got: %s", got)
+	}
+
+	rec = serveMethod(&metadataServer{}, "Add", newJSONRequest("POST", "/Add", `{}`), MetadataToHeader(nil))
+	if got := rec.Header().Get("X-Next-Cursor"); got != "" {
+		t.Errorf("Expect no metadata headers when MetadataToHeader is nil, Got: %s", got)
+	}
+}
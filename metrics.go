@@ -0,0 +1,149 @@
+package grpcj
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+)
+
+// Metrics allows collecting Prometheus metrics for every RPC method, the collectors are registered with the given registerer
+// (e.g. prometheus.DefaultRegisterer). The following metrics are collected:
+//
+//	grpcj_http_requests_total: counter of HTTP requests by method and HTTP status code.
+//	grpcj_rpc_duration_seconds: histogram of the time spent in RPC methods by method and gRPC code.
+//
+// Use MetricsEndpoint to expose the metrics on the same server.
+func Metrics(registerer prometheus.Registerer) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.metricsRegisterer = registerer
+	}
+}
+
+// MetricsEndpoint allows mounting a Prometheus scrape endpoint on the server (e.g. "/metrics").
+// The metrics are gathered from the registerer passed to Metrics if it is also a prometheus.Gatherer, otherwise from prometheus.DefaultGatherer.
+// The endpoint name must include the starting / (e.g. "/metrics").
+func MetricsEndpoint(endpoint string) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.metricsEndpoint = endpoint
+	}
+}
+
+type metrics struct {
+	requests    *prometheus.CounterVec
+	rpcDuration *prometheus.HistogramVec
+}
+
+func newMetrics(registerer prometheus.Registerer) (*metrics, error) {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpcj_http_requests_total",
+		Help: "Total number of HTTP requests by method and HTTP status code.",
+	}, []string{"method", "code"})
+	rpcDuration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpcj_rpc_duration_seconds",
+		Help:    "Time spent in RPC methods by method and gRPC code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "grpc_code"})
+
+	m := &metrics{}
+	var err error
+	if m.requests, err = registerCounterVec(registerer, requests); err != nil {
+		return nil, err
+	}
+	if m.rpcDuration, err = registerHistogramVec(registerer, rpcDuration); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// registerCounterVec registers collector, reusing an identical collector that is already registered (e.g. by another server).
+func registerCounterVec(registerer prometheus.Registerer, collector *prometheus.CounterVec) (*prometheus.CounterVec, error) {
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			if existing, ok := alreadyRegistered.ExistingCollector.(*prometheus.CounterVec); ok {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+	return collector, nil
+}
+
+// registerHistogramVec registers collector, reusing an identical collector that is already registered (e.g. by another server).
+func registerHistogramVec(registerer prometheus.Registerer, collector *prometheus.HistogramVec) (*prometheus.HistogramVec, error) {
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			if existing, ok := alreadyRegistered.ExistingCollector.(*prometheus.HistogramVec); ok {
+				return existing, nil
+			}
+		}
+		return nil, err
+	}
+	return collector, nil
+}
+
+// observeRPC records the duration of an RPC method call. It is a no-op when metrics are disabled.
+func (m *metrics) observeRPC(methodName string, code codes.Code, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.rpcDuration.WithLabelValues(methodName, code.String()).Observe(duration.Seconds())
+}
+
+// instrument wraps handler so that the HTTP status code of every request is counted.
+func (m *metrics) instrument(handler http.Handler, methodName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		m.requests.WithLabelValues(methodName, strconv.Itoa(recorder.status)).Inc()
+	})
+}
+
+// metricsHandler serves the metrics gathered from registerer, or from prometheus.DefaultGatherer if it isn't a prometheus.Gatherer.
+func metricsHandler(registerer prometheus.Registerer) http.Handler {
+	gatherer, ok := registerer.(prometheus.Gatherer)
+	if !ok || gatherer == nil {
+		gatherer = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+// statusRecorder records the status code and the size of the body written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.size += n
+	return n, err
+}
+
+// Unwrap returns the wrapped ResponseWriter, it is used by http.ResponseController, e.g. to hijack WebSocket connections.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,54 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	serverHTTP, err := NewServer(&testServer{}, ServiceDesc(&testServiceDesc), Metrics(registry), MetricsEndpoint("/metrics"))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	failingHTTP, err := NewServer(&testServer{err: status.Error(codes.NotFound, "not found")}, Metrics(registry))
+	if err != nil {
+		t.Fatalf("Error to create a second server with the same registry, Error:%s", err)
+	}
+
+	requests := []struct {
+		handler http.Handler
+		path    string
+		body    string
+	}{
+		{serverHTTP.Handler, "/Add", `{"num_one": 1, "num_two": 2}`},
+		{serverHTTP.Handler, "/Add", `{"num_one": 1, "num_two": 2}`},
+		{serverHTTP.Handler, "/Count", `{"num_one": 3, "num_two": 1}`},
+		{failingHTTP.Handler, "/Add", `{"num_one": 1, "num_two": 2}`},
+	}
+	for _, req := range requests {
+		req.handler.ServeHTTP(httptest.NewRecorder(), newJSONRequest("POST", req.path, req.body))
+	}
+
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`grpcj_http_requests_total{code="200",method="Add"} 2`,
+		`grpcj_http_requests_total{code="404",method="Add"} 1`,
+		`grpcj_http_requests_total{code="200",method="Count"} 1`,
+		`grpcj_rpc_duration_seconds_count{grpc_code="OK",method="Add"} 2`,
+		`grpcj_rpc_duration_seconds_count{grpc_code="NotFound",method="Add"} 1`,
+		`grpcj_rpc_duration_seconds_count{grpc_code="ResourceExhausted",method="Count"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expect the metrics to contain %q, Got: %s", want, body)
+		}
+	}
+}
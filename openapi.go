@@ -0,0 +1,247 @@
+package grpcj
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zang-cloud/grpc-json/jsonpb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// OpenAPISpec returns an OpenAPI 3.0 JSON document describing the endpoints that Serve would register for grpcServer with the given options.
+// The request and response schemas are generated from the proto message descriptors and follow the configured marshaler:
+// field names use the proto names when OrigName is set, enums are integers when EnumsAsInts is set and 64-bit integers are strings
+// when Int64AsString or Uint64AsString are set. Timestamps are integers when TimestampFormat is TimestampUnixMillis.
+// Marshalers other than the jsonpb ones are described like DefaultMarshaler.
+//
+// Bidirectional-streaming methods served over WebSocket are left out.
+//
+// Scalars, nested messages, enums, repeated fields, maps and the well-known Timestamp, Duration, Struct and wrapper types are supported.
+func OpenAPISpec(grpcServer interface{}, options ...func(*serverOpts)) ([]byte, error) {
+	httpServerOpts := applyOptions(options)
+	routes, err := discoverRoutes([]interface{}{grpcServer}, httpServerOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &openAPIGenerator{marshaler: openAPIMarshalerOptions(httpServerOpts.marshaler), schemas: map[string]interface{}{}}
+	paths := map[string]interface{}{}
+	for _, r := range routes {
+		info := r.info(httpServerOpts)
+		if info.ClientStreaming && info.ServerStreaming {
+			// WebSocket endpoints can't be described with OpenAPI.
+			continue
+		}
+		operations := map[string]interface{}{}
+		for _, httpMethod := range info.HTTPMethods {
+			operations[strings.ToLower(httpMethod)] = g.operation(info, httpMethod)
+		}
+		paths[info.Path] = operations
+	}
+
+	title := reflect.TypeOf(grpcServer).String()
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]interface{}{"title": strings.TrimPrefix(title, "*"), "version": "1.0.0"},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// openAPIMarshalerOptions returns the options of the jsonpb marshalers that affect the JSON representation of messages.
+func openAPIMarshalerOptions(marshaler JSONPBMarshaler) jsonpb.Marshaler {
+	switch m := marshaler.(type) {
+	case *jsonpb.Marshaler:
+		return *m
+	case *jsonpb.MarshalerGOGO:
+		return jsonpb.Marshaler(*m)
+	}
+	return *DefaultMarshaler
+}
+
+// openAPIGenerator builds the schemas of proto messages, each message is added once to the components of the document.
+type openAPIGenerator struct {
+	marshaler jsonpb.Marshaler
+	schemas   map[string]interface{}
+}
+
+func (g *openAPIGenerator) operation(info RouteInfo, httpMethod string) map[string]interface{} {
+	responseContentType := contentTypeJSON
+	if info.ServerStreaming {
+		responseContentType = "application/x-ndjson"
+	}
+	operation := map[string]interface{}{
+		"operationId": info.Method,
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "OK",
+				"content": map[string]interface{}{
+					responseContentType: map[string]interface{}{"schema": g.messageTypeSchema(info.ResponseType)},
+				},
+			},
+			"default": map[string]interface{}{"description": "Error"},
+		},
+	}
+	if len(info.HTTPMethods) > 1 {
+		operation["operationId"] = info.Method + "_" + strings.ToLower(httpMethod)
+	}
+
+	md := messageDescriptor(info.RequestType)
+	var parameters []interface{}
+	for _, name := range pathParamNames(info.Path) {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   g.fieldSchema(fieldByName(md, name)),
+		})
+	}
+	switch httpMethod {
+	case http.MethodGet, http.MethodDelete:
+		// The query parameters are decoded into the request message, only its top-level fields are described.
+		for i := 0; i < md.Fields().Len(); i++ {
+			fd := md.Fields().Get(i)
+			parameters = append(parameters, map[string]interface{}{
+				"name":   g.fieldName(fd),
+				"in":     "query",
+				"schema": g.fieldSchema(fd),
+			})
+		}
+	default:
+		requestContentType := contentTypeJSON
+		if info.ClientStreaming {
+			requestContentType = "application/x-ndjson"
+		}
+		operation["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				requestContentType: map[string]interface{}{"schema": g.messageTypeSchema(info.RequestType)},
+			},
+		}
+	}
+	if parameters != nil {
+		operation["parameters"] = parameters
+	}
+	return operation
+}
+
+// messageDescriptor returns the descriptor of a proto message type, which can be a pointer to the message or the message itself.
+func messageDescriptor(t reflect.Type) protoreflect.MessageDescriptor {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	msg := reflect.New(t).Interface().(proto.Message)
+	return proto.MessageReflect(msg).Descriptor()
+}
+
+func (g *openAPIGenerator) messageTypeSchema(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	return g.messageSchema(messageDescriptor(t))
+}
+
+// messageSchema returns the schema of a message, which is a reference to its schema in the components except for well-known types.
+func (g *openAPIGenerator) messageSchema(md protoreflect.MessageDescriptor) map[string]interface{} {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		if g.marshaler.TimestampFormat == jsonpb.TimestampUnixMillis {
+			return map[string]interface{}{"type": "integer", "format": "int64"}
+		}
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]interface{}{"type": "string", "example": "1.5s"}
+	case "google.protobuf.Struct", "google.protobuf.Empty", "google.protobuf.Any":
+		return map[string]interface{}{"type": "object"}
+	case "google.protobuf.Value":
+		return map[string]interface{}{}
+	case "google.protobuf.ListValue":
+		return map[string]interface{}{"type": "array", "items": map[string]interface{}{}}
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return g.fieldSchema(md.Fields().ByName("value"))
+	}
+
+	name := string(md.FullName())
+	ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	if _, ok := g.schemas[name]; ok {
+		return ref
+	}
+	properties := map[string]interface{}{}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	// The schema is registered before its fields are walked so recursive messages refer to themselves.
+	g.schemas[name] = schema
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		properties[g.fieldName(fd)] = g.fieldSchema(fd)
+	}
+	return ref
+}
+
+func (g *openAPIGenerator) fieldName(fd protoreflect.FieldDescriptor) string {
+	if g.marshaler.OrigName {
+		return string(fd.Name())
+	}
+	return fd.JSONName()
+}
+
+func (g *openAPIGenerator) fieldSchema(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{"type": "object", "additionalProperties": g.singularFieldSchema(fd.MapValue())}
+	}
+	if fd.IsList() {
+		return map[string]interface{}{"type": "array", "items": g.singularFieldSchema(fd)}
+	}
+	return g.singularFieldSchema(fd)
+}
+
+func (g *openAPIGenerator) singularFieldSchema(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "format": "uint32"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if g.marshaler.Int64AsString {
+			return map[string]interface{}{"type": "string", "format": "int64"}
+		}
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if g.marshaler.Uint64AsString {
+			return map[string]interface{}{"type": "string", "format": "uint64"}
+		}
+		return map[string]interface{}{"type": "integer", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case protoreflect.StringKind:
+		return map[string]interface{}{"type": "string"}
+	case protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		var enum []interface{}
+		for i := 0; i < values.Len(); i++ {
+			if g.marshaler.EnumsAsInts {
+				enum = append(enum, int32(values.Get(i).Number()))
+			} else {
+				enum = append(enum, string(values.Get(i).Name()))
+			}
+		}
+		if g.marshaler.EnumsAsInts {
+			return map[string]interface{}{"type": "integer", "enum": enum}
+		}
+		return map[string]interface{}{"type": "string", "enum": enum}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return g.messageSchema(fd.Message())
+	}
+	return map[string]interface{}{}
+}
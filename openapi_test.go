@@ -0,0 +1,89 @@
+package grpcj
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/zang-cloud/grpc-json/jsonpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/types/known/typepb"
+)
+
+type specServer struct{}
+
+func (specServer) Describe(ctx context.Context, req *typepb.Field) (*errdetails.ErrorInfo, error) {
+	return &errdetails.ErrorInfo{}, nil
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	tests := []struct {
+		options []func(*serverOpts)
+		want    map[string]string
+	}{
+		{nil, map[string]string{
+			"paths./Describe.post.requestBody.content.application/json.schema.$ref":                 "#/components/schemas/google.protobuf.Field",
+			"paths./Describe.get.parameters.0.name":                                                 "kind",
+			"paths./Describe.post.responses.200.content.application/json.schema.$ref":               "#/components/schemas/google.rpc.ErrorInfo",
+			"components.schemas.google.protobuf.Field.properties.kind.type":                         "integer",
+			"components.schemas.google.protobuf.Field.properties.json_name.type":                    "string",
+			"components.schemas.google.protobuf.Field.properties.options.type":                      "array",
+			"components.schemas.google.protobuf.Field.properties.options.items.$ref":                "#/components/schemas/google.protobuf.Option",
+			"components.schemas.google.rpc.ErrorInfo.properties.metadata.type":                      "object",
+			"components.schemas.google.rpc.ErrorInfo.properties.metadata.additionalProperties.type": "string",
+		}},
+		{[]func(*serverOpts){Marshaler(&jsonpb.Marshaler{OrigName: false, EnumsAsInts: false})}, map[string]string{
+			"components.schemas.google.protobuf.Field.properties.kind.type":     "string",
+			"components.schemas.google.protobuf.Field.properties.kind.enum.1":   "TYPE_DOUBLE",
+			"components.schemas.google.protobuf.Field.properties.jsonName.type": "string",
+		}},
+	}
+	for _, test := range tests {
+		spec, err := OpenAPISpec(specServer{}, test.options...)
+		if err != nil {
+			t.Fatalf("Error to generate the spec, Error:%s", err)
+		}
+		var doc interface{}
+		if err := json.Unmarshal(spec, &doc); err != nil {
+			t.Fatalf("Error to decode the spec, Error:%s", err)
+		}
+		for path, want := range test.want {
+			if got := lookupSpec(doc, path); got != want {
+				t.Errorf("%s: Expect: %q, Got: %v", path, want, got)
+			}
+		}
+	}
+}
+
+// lookupSpec returns the value at a dot separated path in a decoded JSON document.
+// Keys that contain dots, such as schema names, are matched greedily.
+func lookupSpec(doc interface{}, path string) interface{} {
+	if path == "" {
+		return doc
+	}
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path == key {
+				return lookupSpec(child, "")
+			}
+			if len(path) > len(key) && path[:len(key)+1] == key+"." {
+				if got := lookupSpec(child, path[len(key)+1:]); got != nil {
+					return got
+				}
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			index := strconv.Itoa(i)
+			if path == index {
+				return lookupSpec(child, "")
+			}
+			if len(path) > len(index) && path[:len(index)+1] == index+"." {
+				return lookupSpec(child, path[len(index)+1:])
+			}
+		}
+	}
+	return nil
+}
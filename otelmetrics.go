@@ -0,0 +1,67 @@
+package grpcj
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// OTelMetrics allows collecting OpenTelemetry metrics for every RPC method with the meters of the given MeterProvider,
+// alongside or instead of the Prometheus metrics of the Metrics option. The following instruments are recorded,
+// with the Go name of the method as the rpc.method attribute:
+//
+//	grpcj.server.requests: counter of HTTP requests, with the http.response.status_code attribute.
+//	grpcj.server.request.duration: histogram of the duration of HTTP requests in seconds, with the http.response.status_code attribute.
+//	grpcj.server.active_requests: number of HTTP requests in flight.
+//
+// The instruments are created once when the server is built.
+func OTelMetrics(meterProvider metric.MeterProvider) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.meterProvider = meterProvider
+	}
+}
+
+type otelMetrics struct {
+	requests       metric.Int64Counter
+	duration       metric.Float64Histogram
+	activeRequests metric.Int64UpDownCounter
+}
+
+func newOTelMetrics(meterProvider metric.MeterProvider) (*otelMetrics, error) {
+	meter := meterProvider.Meter(instrumentationName)
+	m := &otelMetrics{}
+	var err error
+	if m.requests, err = meter.Int64Counter("grpcj.server.requests",
+		metric.WithDescription("Number of HTTP requests by method and HTTP status code."), metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.Float64Histogram("grpcj.server.request.duration",
+		metric.WithDescription("Duration of HTTP requests by method and HTTP status code."), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if m.activeRequests, err = meter.Int64UpDownCounter("grpcj.server.active_requests",
+		metric.WithDescription("Number of HTTP requests in flight by method."), metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// instrument wraps handler so that the requests to methodName are counted and timed.
+func (m *otelMetrics) instrument(handler http.Handler, methodName string) http.Handler {
+	method := attribute.String("rpc.method", methodName)
+	active := metric.WithAttributeSet(attribute.NewSet(method))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		m.activeRequests.Add(ctx, 1, active)
+		defer m.activeRequests.Add(ctx, -1, active)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		attributes := metric.WithAttributes(method, attribute.Int("http.response.status_code", recorder.status))
+		m.requests.Add(ctx, 1, attributes)
+		m.duration.Record(ctx, time.Since(start).Seconds(), attributes)
+	})
+}
@@ -0,0 +1,69 @@
+package grpcj
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	spans := tracetest.NewSpanRecorder()
+	serverHTTP, err := NewServer(&testServer{},
+		OTelMetrics(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		Tracing(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	for _, body := range []string{`{"num_one": 1}`, `{"num_one": 2}`, `{"num_one": "invalid"}`} {
+		serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), newJSONRequest("POST", "/Add", body))
+	}
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatalf("Error to collect the metrics, Error:%s", err)
+	}
+	instruments := map[string]metricdata.Aggregation{}
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			instruments[m.Name] = m.Data
+		}
+	}
+
+	requests, ok := instruments["grpcj.server.requests"].(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("Expect a grpcj.server.requests counter, Got: %v", instruments)
+	}
+	counts := map[int64]int64{}
+	for _, point := range requests.DataPoints {
+		if method, _ := point.Attributes.Value("rpc.method"); method.AsString() != "Add" {
+			t.Errorf("Expect the rpc.method attribute to be Add, Got: %s", method.AsString())
+		}
+		code, _ := point.Attributes.Value(attribute.Key("http.response.status_code"))
+		counts[code.AsInt64()] = point.Value
+	}
+	if counts[200] != 2 || counts[400] != 1 {
+		t.Errorf("Expect 2 requests with a 200 and 1 with a 400, Got: %v", counts)
+	}
+
+	duration, ok := instruments["grpcj.server.request.duration"].(metricdata.Histogram[float64])
+	if !ok || len(duration.DataPoints) != 2 {
+		t.Errorf("Expect a grpcj.server.request.duration histogram by status code, Got: %v", instruments["grpcj.server.request.duration"])
+	}
+	active, ok := instruments["grpcj.server.active_requests"].(metricdata.Sum[int64])
+	if !ok || len(active.DataPoints) != 1 || active.DataPoints[0].Value != 0 {
+		t.Errorf("Expect no active requests, Got: %v", instruments["grpcj.server.active_requests"])
+	}
+
+	// The failed decoding doesn't reach the method, so only two calls are traced.
+	if ended := len(spans.Ended()); ended != 2 {
+		t.Errorf("Expect 2 spans, Got: %d", ended)
+	}
+}
@@ -0,0 +1,100 @@
+package grpcj
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// pathParamNames returns the names of the wildcard segments of an endpoint pattern, e.g. ["id"] for "/GetUser/{id}".
+func pathParamNames(endpoint string) []string {
+	var names []string
+	for _, segment := range strings.Split(endpoint, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.TrimSuffix(strings.TrimSuffix(segment[1:len(segment)-1], "..."), "$")
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// fieldByName returns the field of md with the given proto or JSON name, like the names accepted by the unmarshaler.
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return md.Fields().ByJSONName(name)
+}
+
+// checkPathParams returns an error if a path parameter doesn't name a singular scalar or enum field of the request message.
+func checkPathParams(methodName string, md protoreflect.MessageDescriptor, names []string) error {
+	for _, name := range names {
+		fd := fieldByName(md, name)
+		if fd == nil {
+			return fmt.Errorf("grpcj: path parameter %q of method %s is not a field of %s", name, methodName, md.FullName())
+		}
+		if fd.IsList() || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			return fmt.Errorf("grpcj: path parameter %q of method %s must be a scalar or enum field", name, methodName)
+		}
+	}
+	return nil
+}
+
+// setPathParams sets the fields of msg named by the path parameters to the values of the matching path segments of r.
+// Path parameters take precedence over the values decoded from the body or the query parameters.
+func setPathParams(r *http.Request, msg proto.Message, names []string) error {
+	m := proto.MessageReflect(msg)
+	for _, name := range names {
+		fd := fieldByName(m.Descriptor(), name)
+		value, err := parseFieldValue(fd, r.PathValue(name))
+		if err != nil {
+			return fmt.Errorf("invalid value for path parameter %q: %v", name, err)
+		}
+		m.Set(fd, value)
+	}
+	return nil
+}
+
+// parseFieldValue parses the string form of a scalar or enum value of fd, enums can be given by name or number.
+func parseFieldValue(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(i)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(i), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(u)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(u), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.EnumKind:
+		if value := fd.Enum().Values().ByName(protoreflect.Name(s)); value != nil {
+			return protoreflect.ValueOfEnum(value.Number()), nil
+		}
+		i, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", fd.Kind())
+}
@@ -0,0 +1,35 @@
+package grpcj
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// peerContext attaches the client of r to ctx as a peer.Peer, like a gRPC server does, so that RPC methods can read
+// the client address with peer.FromContext regardless of the transport. The TLS connection state is set as its AuthInfo.
+func peerContext(ctx context.Context, r *http.Request, httpServerOpts *serverOpts) context.Context {
+	p := &peer.Peer{Addr: clientAddr(r, httpServerOpts)}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS, CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.PrivacyAndIntegrity}}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// clientAddr returns the address of the client that sent r, see TrustedProxies. Addresses taken from the forwarding headers have no port.
+func clientAddr(r *http.Request, httpServerOpts *serverOpts) net.Addr {
+	ip := net.ParseIP(httpServerOpts.clientIP(r))
+	if ip == nil {
+		// e.g. connections accepted on a Unix domain socket.
+		return &net.UnixAddr{Name: r.RemoteAddr, Net: "unix"}
+	}
+	addr := &net.TCPAddr{IP: ip}
+	if host, port, err := net.SplitHostPort(r.RemoteAddr); err == nil && net.ParseIP(host).Equal(ip) {
+		addr.Port, _ = strconv.Atoi(port)
+	}
+	return addr
+}
@@ -0,0 +1,54 @@
+package grpcj
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func TestPeer(t *testing.T) {
+	tests := []struct {
+		remoteAddr   string
+		forwardedFor string
+		proxyCount   int
+		wantAddr     string
+		wantNetwork  string
+	}{
+		{"192.0.2.1:1234", "", 0, "192.0.2.1:1234", "tcp"},
+		{"192.0.2.1:1234", "203.0.113.7, 198.51.100.2", 0, "192.0.2.1:1234", "tcp"},
+		{"192.0.2.1:1234", "203.0.113.7, 198.51.100.2", 1, "198.51.100.2:0", "tcp"},
+		{"192.0.2.1:1234", "", 1, "192.0.2.1:1234", "tcp"},
+		{"[2001:db8::1]:443", "", 0, "[2001:db8::1]:443", "tcp"},
+		{"@", "", 0, "@", "unix"},
+	}
+	for _, test := range tests {
+		req := newJSONRequest("POST", "/Add", `{}`)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		server := &testServer{}
+		serveMethod(server, "Add", req, TrustedProxyCount(test.proxyCount))
+		p, ok := peer.FromContext(server.ctx)
+		if !ok {
+			t.Fatal("Expect a peer on the context")
+		}
+		if p.Addr.String() != test.wantAddr || p.Addr.Network() != test.wantNetwork {
+			t.Errorf("%s %q: Expect: %s %s, Got: %s %s", test.remoteAddr, test.forwardedFor, test.wantNetwork, test.wantAddr, p.Addr.Network(), p.Addr)
+		}
+		if p.AuthInfo != nil {
+			t.Errorf("Expect no AuthInfo without TLS, Got: %v", p.AuthInfo)
+		}
+	}
+
+	req := newJSONRequest("POST", "/Add", `{}`)
+	req.TLS = &tls.ConnectionState{ServerName: "example.com"}
+	server := &testServer{}
+	serveMethod(server, "Add", req)
+	p, _ := peer.FromContext(server.ctx)
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); !ok || info.State.ServerName != "example.com" {
+		t.Errorf("Expect the TLS state as AuthInfo, Got: %v", p.AuthInfo)
+	}
+}
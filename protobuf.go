@@ -0,0 +1,66 @@
+package grpcj
+
+import (
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	gogoproto "github.com/gogo/protobuf/proto"
+	"github.com/golang/protobuf/proto"
+)
+
+// Content types of the protobuf wire format. Requests with one of these Content-Type headers are decoded with proto.Unmarshal
+// and requests that accept one of them get their response marshaled with proto.Marshal, JSON is used otherwise.
+const (
+	ContentTypeProtobuf      = "application/x-protobuf"
+	ContentTypeGRPCProtobuf  = "application/grpc+proto"
+	contentTypeJSON          = "application/json"
+	contentTypeAnyMediaRange = "*/*"
+)
+
+// isProtobufContentType reports whether the media type of a Content-Type header is one of the protobuf wire format content types.
+func isProtobufContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == ContentTypeProtobuf || mediaType == ContentTypeGRPCProtobuf
+}
+
+// protobufResponseContentType returns the protobuf content type the client asked for in its Accept header,
+// or an empty string if the response should be JSON. JSON is preferred when the client accepts both.
+func protobufResponseContentType(r *http.Request) string {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType := strings.TrimSpace(strings.Split(accept, ";")[0])
+		switch mediaType {
+		case contentTypeJSON, contentTypeAnyMediaRange:
+			return ""
+		case ContentTypeProtobuf, ContentTypeGRPCProtobuf:
+			return mediaType
+		}
+	}
+	return ""
+}
+
+// unmarshalProtobuf decodes the protobuf wire format body of r into msg.
+func unmarshalProtobuf(r *http.Request, msg interface{}) error {
+	pb, ok := msg.(proto.Message)
+	if !ok {
+		return errors.New("request message is not a protobuf message")
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if isGogoMessage(pb) {
+		err = gogoproto.Unmarshal(body, pb)
+	} else {
+		err = proto.Unmarshal(body, pb)
+	}
+	if err != nil {
+		return &DecodeError{Reason: ReasonInvalidProtobuf, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package grpcj
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestProtobufContentNegotiation(t *testing.T) {
+	protobufBody, err := proto.Marshal(&addRequest{NumOne: 1, NumTwo: 2})
+	if err != nil {
+		t.Fatalf("Error to marshal the request, Error:%s", err)
+	}
+	tests := []struct {
+		contentType     string
+		body            []byte
+		accept          string
+		wantContentType string
+	}{
+		{"application/json", []byte(`{"num_one": 1, "num_two": 2}`), "", defaultContentType},
+		{"application/json", []byte(`{"num_one": 1, "num_two": 2}`), "application/x-protobuf", "application/x-protobuf"},
+		{"application/x-protobuf", protobufBody, "", defaultContentType},
+		{"application/grpc+proto", protobufBody, "application/grpc+proto", "application/grpc+proto"},
+		{"application/x-protobuf", protobufBody, "application/json, application/x-protobuf", defaultContentType},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("POST", "/Add", bytes.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		req.Header.Set("Accept", test.accept)
+		rec := serveMethod(&testServer{}, "Add", req)
+
+		if contentType := rec.Header().Get("Content-Type"); contentType != test.wantContentType {
+			t.Errorf("%s accepting %q: Expect Content-Type: %s, Got: %s", test.contentType, test.accept, test.wantContentType, contentType)
+			continue
+		}
+		var resp addResponse
+		if test.wantContentType == defaultContentType {
+			err = DefaultUnmarshaler.Unmarshal(rec.Body, &resp)
+		} else {
+			err = proto.Unmarshal(rec.Body.Bytes(), &resp)
+		}
+		if err != nil {
+			t.Errorf("%s accepting %q: Error to decode the response, Error:%s", test.contentType, test.accept, err)
+		} else if resp.Sum != 3 {
+			t.Errorf("%s accepting %q: Expect sum: 3, Got: %d", test.contentType, test.accept, resp.Sum)
+		}
+	}
+}
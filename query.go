@@ -0,0 +1,217 @@
+package grpcj
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/joncalhoun/qson"
+	"github.com/zang-cloud/grpc-json/jsonpb"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// QueryDecoderFunc decodes the query parameters of a GET or DELETE request into the request message.
+type QueryDecoderFunc func(query url.Values, msg proto.Message) error
+
+// QueryDecoder allows replacing how the query parameters of GET and DELETE requests are decoded into the request message,
+// e.g. QueryDecoder(DescriptorQueryDecoder(false)). By default the query is converted to JSON with qson and decoded
+// with the configured unmarshaler. The decoder is also used for the query parameters merged by MergeQueryParams.
+// DescriptorQueryDecoder skips the JSON conversion, which makes it several times faster for GET-heavy services
+// that don't need the qson syntax for nested fields.
+func QueryDecoder(decoder QueryDecoderFunc) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.queryDecoder = decoder
+	}
+}
+
+// DescriptorQueryDecoder returns a QueryDecoderFunc that sets the fields of the request message from the query parameters
+// using the field descriptors, without converting the query to JSON. Parameters are named after the proto or JSON names
+// of the fields and nested fields are separated by dots (e.g. "user.name=bob"). Repeated fields take all the values of their
+// parameter (e.g. "ids=1&ids=2"), enums can be given by name or number and booleans accept the values of strconv.ParseBool.
+// The Timestamp, Duration and wrapper well-known types are supported, maps and repeated messages are not.
+// Unknown parameters are rejected unless allowUnknownFields is true.
+func DescriptorQueryDecoder(allowUnknownFields bool) QueryDecoderFunc {
+	return func(query url.Values, msg proto.Message) error {
+		m := proto.MessageReflect(msg)
+		for key, values := range query {
+			if err := setQueryField(m, key, strings.Split(key, "."), values); err != nil {
+				if _, unknown := err.(unknownQueryParamError); unknown && allowUnknownFields {
+					continue
+				}
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+type unknownQueryParamError string
+
+func (e unknownQueryParamError) Error() string {
+	return fmt.Sprintf("unknown query parameter %q", string(e))
+}
+
+// setQueryField sets the field of m at path to the values of the query parameter key.
+func setQueryField(m protoreflect.Message, key string, path []string, values []string) error {
+	fd := fieldByName(m.Descriptor(), path[0])
+	if fd == nil {
+		return unknownQueryParamError(key)
+	}
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		if set := m.WhichOneof(od); set != nil && set != fd {
+			return fmt.Errorf("query parameter %q: oneof %s can only have one of its fields set, got %s and %s", key, od.Name(), set.Name(), fd.Name())
+		}
+	}
+	isMessage := fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind
+	if len(path) > 1 {
+		if !isMessage || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("query parameter %q: %s is not a message field", key, fd.Name())
+		}
+		return setQueryField(m.Mutable(fd).Message(), key, path[1:], values)
+	}
+
+	switch {
+	case fd.IsMap() || (fd.IsList() && isMessage):
+		return fmt.Errorf("query parameter %q: maps and repeated messages are not supported", key)
+	case fd.IsList():
+		list := m.Mutable(fd).List()
+		for _, value := range values {
+			v, err := parseFieldValue(fd, value)
+			if err != nil {
+				return fmt.Errorf("invalid value for query parameter %q: %v", key, err)
+			}
+			list.Append(v)
+		}
+		return nil
+	case isMessage:
+		return setWellKnownQueryField(m.Mutable(fd).Message(), key, values[len(values)-1])
+	}
+	v, err := parseFieldValue(fd, values[len(values)-1])
+	if err != nil {
+		return fmt.Errorf("invalid value for query parameter %q: %v", key, err)
+	}
+	m.Set(fd, v)
+	return nil
+}
+
+// setWellKnownQueryField sets a Timestamp, Duration or wrapper message from the value of a query parameter.
+func setWellKnownQueryField(m protoreflect.Message, key, value string) error {
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration":
+		quoted, _ := json.Marshal(value)
+		if err := jsonpb.Unmarshal(bytes.NewReader(quoted), proto.MessageV1(m.Interface())); err != nil {
+			return fmt.Errorf("invalid value for query parameter %q: %v", key, err)
+		}
+		return nil
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value", "google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		fd := m.Descriptor().Fields().ByName("value")
+		v, err := parseFieldValue(fd, value)
+		if err != nil {
+			return fmt.Errorf("invalid value for query parameter %q: %v", key, err)
+		}
+		m.Set(fd, v)
+		return nil
+	}
+	return fmt.Errorf("query parameter %q: message %s is not supported", key, m.Descriptor().FullName())
+}
+
+// decodeQuery decodes the query parameters of r into msg with the QueryDecoder, or with qson and the configured unmarshaler.
+// Requests without query parameters leave msg empty, every field keeps its default value.
+func decodeQuery(r *http.Request, msg interface{}, httpServerOpts *serverOpts) error {
+	if strings.TrimSpace(r.URL.RawQuery) == "" {
+		return nil
+	}
+	if pb, ok := msg.(proto.Message); ok && httpServerOpts.queryDecoder != nil {
+		query, err := url.ParseQuery(r.URL.RawQuery)
+		if err != nil {
+			return err
+		}
+		return httpServerOpts.queryDecoder(query, pb)
+	}
+	parsedJSON, err := queryToJSON(r.URL.RawQuery, msg)
+	if err != nil {
+		return err
+	}
+	return unmarshalerFor(httpServerOpts.unmarshaler, msg).Unmarshal(bytes.NewReader(parsedJSON), msg)
+}
+
+// queryToJSON converts the query parameters of a request into a JSON object that can be unmarshaled into msg.
+// Nested fields use the qson syntax (e.g. "user[name]=bob"). Repeated fields of msg are set from all the values
+// of their key (e.g. "ids=1&ids=2&ids=3"), which qson alone would reduce to a single value.
+func queryToJSON(rawQuery string, msg interface{}) ([]byte, error) {
+	parsedJSON, err := qson.ToJSON(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+	pb, ok := msg.(proto.Message)
+	if !ok {
+		return parsedJSON, nil
+	}
+	md := proto.MessageReflect(pb).Descriptor()
+	if !hasListField(md) {
+		// The query only needs to be parsed again to collect the values of repeated fields.
+		return parsedJSON, nil
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	for key, values := range query {
+		fd := fieldByName(md, key)
+		if fd == nil || !fd.IsList() {
+			continue
+		}
+		if fields == nil {
+			if err := json.Unmarshal(parsedJSON, &fields); err != nil {
+				return nil, err
+			}
+		}
+		list := make([]json.RawMessage, 0, len(values))
+		for _, value := range values {
+			list = append(list, queryValueToJSON(fd, value))
+		}
+		fields[key], _ = json.Marshal(list)
+	}
+	if fields == nil {
+		return parsedJSON, nil
+	}
+	return json.Marshal(fields)
+}
+
+// hasListField reports whether md has a repeated field.
+func hasListField(md protoreflect.MessageDescriptor) bool {
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fields.Get(i).IsList() {
+			return true
+		}
+	}
+	return false
+}
+
+// queryValueToJSON returns the JSON representation of a single query parameter value for the element type of a repeated field.
+// Numbers and booleans are written as JSON literals when they are valid ones, and enums can be either a name or a number.
+func queryValueToJSON(fd protoreflect.FieldDescriptor, value string) json.RawMessage {
+	switch fd.Kind() {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		// Always quoted.
+	case protoreflect.BoolKind:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return json.RawMessage(strconv.FormatBool(b))
+		}
+	default:
+		if _, err := strconv.ParseFloat(value, 64); err == nil && json.Valid([]byte(value)) {
+			return json.RawMessage(value)
+		}
+	}
+	raw, _ := json.Marshal(value)
+	return raw
+}
@@ -0,0 +1,201 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRepeatedQueryParams(t *testing.T) {
+	tests := []struct {
+		query string
+		msg   proto.Message
+		want  proto.Message
+	}{
+		{"path=1&path=2&path=3", &descriptorpb.SourceCodeInfo_Location{}, &descriptorpb.SourceCodeInfo_Location{Path: []int32{1, 2, 3}}},
+		{"path=4", &descriptorpb.SourceCodeInfo_Location{}, &descriptorpb.SourceCodeInfo_Location{Path: []int32{4}}},
+		{
+			"leading_comments=note&leadingDetachedComments=a&leadingDetachedComments=b",
+			&descriptorpb.SourceCodeInfo_Location{},
+			&descriptorpb.SourceCodeInfo_Location{LeadingComments: proto.String("note"), LeadingDetachedComments: []string{"a", "b"}},
+		},
+		{
+			"targets=TARGET_TYPE_FILE&targets=TARGET_TYPE_FIELD&targets=9",
+			&descriptorpb.FieldOptions{},
+			&descriptorpb.FieldOptions{Targets: []descriptorpb.FieldOptions_OptionTargetType{
+				descriptorpb.FieldOptions_TARGET_TYPE_FILE,
+				descriptorpb.FieldOptions_TARGET_TYPE_FIELD,
+				descriptorpb.FieldOptions_TARGET_TYPE_METHOD,
+			}},
+		},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/Method?"+test.query, nil)
+		if err := decodeRequest(req, test.msg, applyOptions(nil)); err != nil {
+			t.Errorf("%s: Error to decode the request, Error:%s", test.query, err)
+			continue
+		}
+		if !proto.Equal(test.msg, test.want) {
+			t.Errorf("%s: Expect: %v, Got: %v", test.query, test.want, test.msg)
+		}
+	}
+}
+
+func TestDescriptorQueryDecoder(t *testing.T) {
+	tests := []struct {
+		query              string
+		allowUnknownFields bool
+		msg                proto.Message
+		want               proto.Message
+		wantErr            bool
+	}{
+		{
+			"name=foo.proto&options.java_package=com.example&options.javaMultipleFiles=true&options.optimize_for=CODE_SIZE",
+			false,
+			&descriptorpb.FileDescriptorProto{},
+			&descriptorpb.FileDescriptorProto{Name: proto.String("foo.proto"), Options: &descriptorpb.FileOptions{
+				JavaPackage:       proto.String("com.example"),
+				JavaMultipleFiles: proto.Bool(true),
+				OptimizeFor:       descriptorpb.FileOptions_CODE_SIZE.Enum(),
+			}},
+			false,
+		},
+		{
+			"dependency=a.proto&dependency=b.proto&public_dependency=1&public_dependency=0",
+			false,
+			&descriptorpb.FileDescriptorProto{},
+			&descriptorpb.FileDescriptorProto{Dependency: []string{"a.proto", "b.proto"}, PublicDependency: []int32{1, 0}},
+			false,
+		},
+		{
+			"time=1986-03-10T05:05:05.005Z",
+			false,
+			&timestampMessage{},
+			&timestampMessage{Time: &timestamp.Timestamp{Seconds: 510815105, Nanos: 5000000}},
+			false,
+		},
+		{"name=foo.proto&_=123", true, &descriptorpb.FileDescriptorProto{}, &descriptorpb.FileDescriptorProto{Name: proto.String("foo.proto")}, false},
+		{"name=foo.proto&_=123", false, &descriptorpb.FileDescriptorProto{}, nil, true},
+		{"options.java_package.x=1", false, &descriptorpb.FileDescriptorProto{}, nil, true},
+		{"message_type.name=Foo", false, &descriptorpb.FileDescriptorProto{}, nil, true},
+		{"options.javaMultipleFiles=maybe", false, &descriptorpb.FileDescriptorProto{}, nil, true},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/Method?"+test.query, nil)
+		err := decodeRequest(req, test.msg, applyOptions([]func(*serverOpts){QueryDecoder(DescriptorQueryDecoder(test.allowUnknownFields))}))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: Expect an error, Got: %v", test.query, test.msg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Error to decode the request, Error:%s", test.query, err)
+			continue
+		}
+		if !proto.Equal(test.msg, test.want) {
+			t.Errorf("%s: Expect: %v, Got: %v", test.query, test.want, test.msg)
+		}
+	}
+}
+
+func TestQueryDecoder(t *testing.T) {
+	decoder := func(query url.Values, msg proto.Message) error {
+		req := msg.(*addRequest)
+		req.NumOne, req.NumTwo = int64(len(query.Get("a"))), int64(len(query.Get("b")))
+		return nil
+	}
+	rec := serveMethod(&testServer{}, "Add", httptest.NewRequest("GET", "/Add?a=xx&b=yyy", nil), QueryDecoder(decoder))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"sum":5}` {
+		t.Errorf("Expect: 200 {\"sum\":5}, Got: %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEmptyQuery(t *testing.T) {
+	for _, target := range []string{"/Add", "/Add?"} {
+		for _, options := range [][]func(*serverOpts){nil, {QueryDecoder(DescriptorQueryDecoder(false))}} {
+			rec := serveMethod(&testServer{}, "Add", httptest.NewRequest("GET", target, nil), options...)
+			if rec.Code != http.StatusOK || rec.Body.String() != `{"sum":0}` {
+				t.Errorf("%s: Expect: 200 {\"sum\":0}, Got: %d %s", target, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+// filterRequest is a request with a oneof, like the messages generated for "oneof filter { int64 by_id = 1; string by_email = 2; }".
+type filterRequest struct {
+	Filter isFilterRequest_Filter `protobuf_oneof:"filter"`
+	Limit  int64                  `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+type isFilterRequest_Filter interface {
+	isFilterRequest_Filter()
+}
+
+type filterRequest_ById struct {
+	ById int64 `protobuf:"varint,1,opt,name=by_id,json=byId,proto3,oneof"`
+}
+
+type filterRequest_ByEmail struct {
+	ByEmail string `protobuf:"bytes,2,opt,name=by_email,json=byEmail,proto3,oneof"`
+}
+
+func (*filterRequest_ById) isFilterRequest_Filter()    {}
+func (*filterRequest_ByEmail) isFilterRequest_Filter() {}
+
+func (m *filterRequest) Reset()         { *m = filterRequest{} }
+func (m *filterRequest) String() string { return proto.CompactTextString(m) }
+func (*filterRequest) ProtoMessage()    {}
+func (*filterRequest) XXX_OneofWrappers() []interface{} {
+	return []interface{}{(*filterRequest_ById)(nil), (*filterRequest_ByEmail)(nil)}
+}
+
+func TestOneofQueryParams(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantFilter isFilterRequest_Filter
+		wantErr    bool
+	}{
+		{"by_email=a@example.com&limit=2", &filterRequest_ByEmail{ByEmail: "a@example.com"}, false},
+		{"byId=7", &filterRequest_ById{ById: 7}, false},
+		{"limit=2", nil, false},
+		{"", nil, false},
+		{"by_id=7&by_email=a@example.com", nil, true},
+		{"byId=7&by_email=a@example.com", nil, true},
+	}
+	decoders := map[string][]func(*serverOpts){
+		"qson":       nil,
+		"descriptor": {QueryDecoder(DescriptorQueryDecoder(false))},
+	}
+	for name, options := range decoders {
+		for _, test := range tests {
+			msg := &filterRequest{}
+			err := decodeRequest(httptest.NewRequest("GET", "/Filter?"+test.query, nil), msg, applyOptions(options))
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "oneof filter") {
+					t.Errorf("%s %s: Expect an error about oneof filter, Got: %v", name, test.query, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %s: Error to decode the request, Error:%s", name, test.query, err)
+				continue
+			}
+			if !reflect.DeepEqual(msg.Filter, test.wantFilter) {
+				t.Errorf("%s %s: Expect filter: %#v, Got: %#v", name, test.query, test.wantFilter, msg.Filter)
+			}
+		}
+	}
+
+	req := newJSONRequest("POST", "/Filter", `{"by_id": 7, "byEmail": "a@example.com"}`)
+	if err := decodeRequest(req, &filterRequest{}, applyOptions(nil)); err == nil || !strings.Contains(err.Error(), "oneof filter") {
+		t.Errorf("Expect an error about oneof filter for a body with both fields, Got: %v", err)
+	}
+}
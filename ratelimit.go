@@ -0,0 +1,116 @@
+package grpcj
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const defaultRateLimitTTL = 3 * time.Minute
+
+// RateLimitOption configures the RateLimit middleware.
+type RateLimitOption func(*rateLimitOpts)
+
+type rateLimitOpts struct {
+	trustForwardedFor bool
+	ttl               time.Duration
+}
+
+// RateLimitTrustForwardedFor allows identifying clients by the X-Forwarded-For header instead of the remote address. Default is false.
+// Only enable it behind a proxy that sets the header, the last address of the header (the one added by the proxy) is used.
+//
+// Deprecated: RateLimit identifies clients by the IP resolved with the TrustedProxies and TrustedProxyCount options,
+// which are shared with the other features that need the client IP. RateLimitTrustForwardedFor(true) makes this middleware
+// resolve the client IP like TrustedProxyCount(1), from the Forwarded or X-Forwarded-For header, instead of with the server options.
+func RateLimitTrustForwardedFor(trust bool) RateLimitOption {
+	return func(o *rateLimitOpts) {
+		o.trustForwardedFor = trust
+	}
+}
+
+// RateLimitTTL sets how long the limiter of a client is kept after its last request. Default is 3 minutes.
+func RateLimitTTL(ttl time.Duration) RateLimitOption {
+	return func(o *rateLimitOpts) {
+		o.ttl = ttl
+	}
+}
+
+// RateLimit is a MiddlewareFunc that limits each client IP to r requests per second with bursts of up to burst requests,
+// see golang.org/x/time/rate. Requests over the limit are rejected with a 429 and a Retry-After header.
+// The client IP is resolved with the TrustedProxies and TrustedProxyCount options.
+func RateLimit(r rate.Limit, burst int, opts ...RateLimitOption) MiddlewareFunc {
+	o := &rateLimitOpts{ttl: defaultRateLimitTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	limiters := &clientLimiters{limit: r, burst: burst, ttl: o.ttl, limiters: map[string]*clientLimiter{}}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			now := time.Now()
+			ip, ok := ClientIPFromContext(req.Context())
+			if o.trustForwardedFor {
+				ip = trustLastProxy.clientIP(req)
+			} else if !ok {
+				ip = remoteHost(req)
+			}
+			reservation := limiters.get(ip, now).ReserveN(now, 1)
+			if !reservation.OK() {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			if delay := reservation.DelayFrom(now); delay > 0 {
+				reservation.CancelAt(now)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// trustLastProxy resolves the client IP like TrustedProxyCount(1), for the deprecated RateLimitTrustForwardedFor option.
+var trustLastProxy = &serverOpts{trustedProxyCount: 1}
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// clientLimiters holds a limiter for each client, the limiters of clients that haven't been seen for the TTL are removed.
+type clientLimiters struct {
+	limit rate.Limit
+	burst int
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	limiters    map[string]*clientLimiter
+	lastCleanup time.Time
+}
+
+func (c *clientLimiters) get(ip string, now time.Time) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastCleanup) > c.ttl {
+		for key, limiter := range c.limiters {
+			if now.Sub(limiter.lastSeen) > c.ttl {
+				delete(c.limiters, key)
+			}
+		}
+		c.lastCleanup = now
+	}
+
+	limiter, ok := c.limiters[ip]
+	if !ok {
+		limiter = &clientLimiter{Limiter: rate.NewLimiter(c.limit, c.burst)}
+		c.limiters[ip] = limiter
+	}
+	limiter.lastSeen = now
+	return limiter.Limiter
+}
@@ -0,0 +1,113 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		options    []RateLimitOption
+		remoteAddr string
+		forwarded  string
+		wantStatus int
+	}{
+		{nil, "10.0.0.1:1234", "", http.StatusOK},
+		{nil, "10.0.0.1:1234", "", http.StatusOK},
+		{nil, "10.0.0.1:5678", "", http.StatusTooManyRequests},
+		{nil, "10.0.0.2:1234", "", http.StatusOK},
+		// The forwarded address is ignored unless trusted, so the client is still 10.0.0.2.
+		{nil, "10.0.0.2:1234", "192.168.0.1", http.StatusOK},
+		{nil, "10.0.0.2:1234", "192.168.0.2", http.StatusTooManyRequests},
+		{[]RateLimitOption{RateLimitTrustForwardedFor(true)}, "10.0.0.3:1234", "1.1.1.1, 192.168.0.1", http.StatusOK},
+		{[]RateLimitOption{RateLimitTrustForwardedFor(true)}, "10.0.0.3:1234", "192.168.0.2", http.StatusOK},
+	}
+	var serverHTTP *http.Server
+	for i, test := range tests {
+		// A new server is created when the options change, the limiters are kept otherwise.
+		if i == 0 || i == 6 {
+			var err error
+			serverHTTP, err = NewServer(&testServer{}, Middleware(RateLimit(rate.Every(time.Hour), 2, test.options...)))
+			if err != nil {
+				t.Fatalf("Error to create the server, Error:%s", err)
+			}
+		}
+		req := newJSONRequest("POST", "/Add", `{}`)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%d: Expect status: %d, Got: %d", i, test.wantStatus, rec.Code)
+		}
+		if test.wantStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "3600" {
+			t.Errorf("%d: Expect Retry-After: 3600, Got: %q", i, rec.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestRateLimitTrustedProxies(t *testing.T) {
+	serverHTTP, err := NewServer(&testServer{}, Middleware(RateLimit(rate.Every(time.Hour), 1)), TrustedProxyCount(1))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	for i, test := range []struct {
+		forwarded  string
+		wantStatus int
+	}{
+		{"1.1.1.1", http.StatusOK},
+		{"2.2.2.2", http.StatusOK},
+		// Clients can't get a new limiter by prepending an address.
+		{"3.3.3.3, 1.1.1.1", http.StatusTooManyRequests},
+	} {
+		req := newJSONRequest("POST", "/Add", `{}`)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", test.forwarded)
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%d: Expect status: %d, Got: %d", i, test.wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitTrustForwardedFor(t *testing.T) {
+	serverHTTP, err := NewServer(&testServer{}, Middleware(RateLimit(rate.Every(time.Hour), 1, RateLimitTrustForwardedFor(true))))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	for i, test := range []struct {
+		forwarded  string
+		wantStatus int
+	}{
+		{`for=1.1.1.1`, http.StatusOK},
+		{`for="[2001:db8::17]:4711"`, http.StatusOK},
+		{`for=3.3.3.3, for=1.1.1.1`, http.StatusTooManyRequests},
+	} {
+		req := newJSONRequest("POST", "/Add", `{}`)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Forwarded", test.forwarded)
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%d: Expect status: %d, Got: %d", i, test.wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestRateLimitCleanup(t *testing.T) {
+	limiters := &clientLimiters{limit: 1, burst: 1, ttl: time.Minute, limiters: map[string]*clientLimiter{}}
+	start := time.Now()
+	limiters.get("10.0.0.1", start)
+	limiters.get("10.0.0.2", start.Add(90*time.Second))
+	limiters.get("10.0.0.3", start.Add(2*time.Minute))
+	if len(limiters.limiters) != 2 {
+		t.Errorf("Expect the stale limiter to be removed, Got: %d limiters", len(limiters.limiters))
+	}
+}
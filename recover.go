@@ -0,0 +1,47 @@
+package grpcj
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+
+	"google.golang.org/grpc/codes"
+)
+
+// RecoverPanics allows disabling the recovery of panics in RPC methods. Default is true.
+// When enabled, a panicking method is logged with its stack trace and the request is answered with a 500 and a JSON error body
+// instead of crashing the server. Disable it to handle panics in your own middleware.
+func RecoverPanics(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.recoverPanics = enabled
+	}
+}
+
+// recoverHandler wraps handler so that panics are recovered and answered with a 500. http.ErrAbortHandler is panicked again
+// to let net/http abort the response, and so are panics after the response has started (e.g. in the middle of a stream),
+// once logged, since a 500 can't be written anymore.
+func recoverHandler(handler http.Handler, httpServerOpts *serverOpts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			r.Body.Close()
+			httpServerOpts.logger.Errorf("Recovered from panic serving %s: %v\n%s", r.URL.Path, recovered, debug.Stack())
+			if recorder.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			writeJSONError(w, ErrorBody{
+				Code:    codeName(codes.Internal),
+				Message: "Internal server error",
+				Details: []json.RawMessage{},
+			}, http.StatusInternalServerError, httpServerOpts.jsonErrorContentType())
+		}()
+		handler.ServeHTTP(recorder, r)
+	})
+}
@@ -0,0 +1,97 @@
+package grpcj
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type panicServer struct{}
+
+func (s *panicServer) Add(ctx context.Context, req *addRequest) (*addResponse, error) {
+	var m map[string]int
+	m["boom"]++
+	return &addResponse{}, nil
+}
+
+func TestRecoverPanics(t *testing.T) {
+	serverHTTP, err := NewServer(&panicServer{})
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{}`))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expect status: 500, Got: %d", rec.Code)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "INTERNAL" {
+		t.Errorf("Expect an INTERNAL JSON error body, Got: %s", rec.Body.String())
+	}
+
+	serverHTTP, err = NewServer(&panicServer{}, RecoverPanics(false))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expect the panic to propagate when RecoverPanics is false")
+		}
+	}()
+	serverHTTP.Handler.ServeHTTP(httptest.NewRecorder(), newJSONRequest("POST", "/Add", `{}`))
+}
+
+func TestRecoverAbortedResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantBody string
+	}{
+		{"abort", func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) }, ""},
+		{"started", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"sum":1}` + "\n"))
+			panic("boom")
+		}, `{"sum":1}` + "\n"},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		func() {
+			defer func() {
+				if recovered := recover(); recovered != http.ErrAbortHandler {
+					t.Errorf("%s: Expect the response to be aborted, Got: %v", test.name, recovered)
+				}
+			}()
+			recoverHandler(test.handler, applyOptions(nil)).ServeHTTP(rec, newJSONRequest("POST", "/Count", `{}`))
+		}()
+		if rec.Body.String() != test.wantBody {
+			t.Errorf("%s: Expect no error body to be appended, Got: %q", test.name, rec.Body.String())
+		}
+	}
+}
+
+func TestErrorContentTypeOfPanicsAndNotFound(t *testing.T) {
+	const contentType = "application/problem+json"
+	server := &panicServer{}
+	serverHTTP, err := NewServerAll([]interface{}{server, &subtractServer{}},
+		NotFoundHandler(nil), ErrorContentType(contentType), AllowedMethods([]interface{}{server.Add}))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/Add", http.StatusInternalServerError},
+		{"/Unknown", http.StatusNotFound},
+		{"/Subtract", http.StatusNotFound},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, `{}`))
+		if got := rec.Header().Get("Content-Type"); rec.Code != test.wantStatus || got != contentType {
+			t.Errorf("%s: Expect %d with Content-Type: %s, Got: %d %s", test.path, test.wantStatus, contentType, rec.Code, got)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header used by the RequestID middleware to read and return the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID stored on the context by the RequestID middleware.
+// It can be used with the HTTP request context in middleware as well as with the context passed to RPC methods.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(requestIDKey{}).(string)
+	return requestID, ok
+}
+
+// RequestID is a MiddlewareFunc that assigns an ID to every request. The ID is read from the incoming X-Request-ID header,
+// or a UUID is generated when the header is missing, and returned in the X-Request-ID response header.
+// The ID is forwarded to RPC methods as the "x-request-id" incoming metadata and can also be read with RequestIDFromContext.
+func RequestID() MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = uuid.New().String()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID)))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package grpcj
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestID(t *testing.T) {
+	server := &testServer{}
+	serverHTTP, err := NewServer(server, Middleware(RequestID()))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	req := newJSONRequest("POST", "/Add", `{}`)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("Expect: abc-123, Got: %s", got)
+	}
+	if got, _ := RequestIDFromContext(server.ctx); got != "abc-123" {
+		t.Errorf("Expect: abc-123, Got: %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", `{}`))
+	generated := rec.Header().Get("X-Request-ID")
+	if len(generated) != 36 {
+		t.Errorf("Expect a generated UUID, Got: %q", generated)
+	}
+	md, _ := metadata.FromIncomingContext(server.ctx)
+	if got := md.Get("x-request-id"); len(got) != 1 || got[0] != generated {
+		t.Errorf("Expect metadata x-request-id: %s, Got: %v", generated, got)
+	}
+}
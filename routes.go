@@ -0,0 +1,265 @@
+package grpcj
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RouteInfo describes an endpoint served for an RPC method.
+type RouteInfo struct {
+	// Path is the URL path of the endpoint, including the PathPrefix.
+	Path string
+	// Method is the Go name of the RPC method (e.g. "Add").
+	Method string
+	// HTTPMethods are the HTTP verbs accepted by the endpoint, see AllowedHTTPMethods.
+	HTTPMethods []string
+	// RequestType and ResponseType are the types of the request and response messages (e.g. *pb.AddRequest).
+	RequestType  reflect.Type
+	ResponseType reflect.Type
+	// ServerStreaming is true for server-streaming methods, which respond with a stream of ResponseType messages.
+	ServerStreaming bool
+	// ClientStreaming is true for client-streaming methods, which receive a stream of RequestType messages.
+	ClientStreaming bool
+	// Both are true for bidirectional-streaming methods, which are served over WebSocket, see the WebSocket option.
+}
+
+// Routes returns the endpoints that Serve would register for grpcServer with the given options, sorted by path, without starting a server.
+// It can be used to list the available endpoints, e.g. to generate a manifest or client code.
+func Routes(grpcServer interface{}, options ...func(*serverOpts)) ([]RouteInfo, error) {
+	httpServerOpts := applyOptions(options)
+	routes, err := discoverRoutes([]interface{}{grpcServer}, httpServerOpts)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, r := range routes {
+		infos = append(infos, r.info(httpServerOpts))
+	}
+	return infos, nil
+}
+
+// route is an endpoint discovered on the gRPC server or added with AddEndpoints.
+type route struct {
+	// server is the gRPC server of the method, it is nil for added endpoints when there are several servers.
+	server     interface{}
+	endpoint   string
+	methodName string
+	methodFunc reflect.Value
+	// streamDesc is set for streaming methods.
+	streamDesc *grpc.StreamDesc
+}
+
+func (r route) info(httpServerOpts *serverOpts) RouteInfo {
+	info := RouteInfo{
+		Path:        httpServerOpts.routePath(r.endpoint),
+		Method:      r.methodName,
+		HTTPMethods: append([]string(nil), httpServerOpts.allowedHTTPMethods(r.endpoint)...),
+	}
+	methodType := r.methodFunc.Type()
+	if r.streamDesc != nil && r.streamDesc.ClientStreams && r.streamDesc.ServerStreams {
+		// Bidirectional-streaming methods are of the form func(Service_MethodServer) error, where the stream has
+		// Recv() (*Request, error) and Send(*Response) error methods. They are served over WebSocket, which starts with a GET request.
+		if recv, ok := methodType.In(0).MethodByName("Recv"); ok && recv.Type.NumOut() == 2 {
+			info.RequestType = recv.Type.Out(0)
+		}
+		if send, ok := methodType.In(0).MethodByName("Send"); ok && send.Type.NumIn() == 1 {
+			info.ResponseType = send.Type.In(0)
+		}
+		info.HTTPMethods = []string{http.MethodGet}
+		info.ClientStreaming = true
+		info.ServerStreaming = true
+		return info
+	}
+	if r.streamDesc != nil && r.streamDesc.ClientStreams {
+		// Client-streaming methods are of the form func(Service_MethodServer) error, where the stream has
+		// Recv() (*Request, error) and SendAndClose(*Response) error methods.
+		if recv, ok := methodType.In(0).MethodByName("Recv"); ok && recv.Type.NumOut() == 2 {
+			info.RequestType = recv.Type.Out(0)
+		}
+		if sendAndClose, ok := methodType.In(0).MethodByName("SendAndClose"); ok && sendAndClose.Type.NumIn() == 1 {
+			info.ResponseType = sendAndClose.Type.In(0)
+		}
+		info.ClientStreaming = true
+		return info
+	}
+	if r.streamDesc != nil {
+		// Server-streaming methods are of the form func(*Request, Service_MethodServer) error, where the stream has a Send(*Response) error method.
+		info.RequestType = methodType.In(0)
+		if send, ok := methodType.In(1).MethodByName("Send"); ok && send.Type.NumIn() == 1 {
+			info.ResponseType = send.Type.In(0)
+		}
+		info.ServerStreaming = true
+		return info
+	}
+	info.RequestType = methodType.In(1)
+	info.ResponseType = methodType.Out(0)
+	return info
+}
+
+// discoverRoutes returns the RPC methods of grpcServers and the endpoints added with AddEndpoints, sorted by path.
+// Methods that are not RPC methods are skipped, an error is returned for RPC methods with unsupported request or response types
+// and for methods served at the same path.
+func discoverRoutes(grpcServers []interface{}, httpServerOpts *serverOpts) ([]route, error) {
+	// With OverrideDefaultEndpoints, the methods of the added endpoints are not served at their default endpoint.
+	overridden := map[string]bool{}
+	if httpServerOpts.overrideDefaults {
+		for _, method := range httpServerOpts.endpointToMethodMap {
+			overridden[shortMethodName(runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name())] = true
+		}
+	}
+
+	var routes []route
+	for _, grpcServer := range grpcServers {
+		if grpcServer == nil {
+			return nil, errors.New("grpcServer must not be nil")
+		}
+		if !httpServerOpts.autoRegistration {
+			continue
+		}
+		grpcServerType := reflect.TypeOf(grpcServer)
+		for i := 0; i < grpcServerType.NumMethod(); i++ {
+			methodName := grpcServerType.Method(i).Name
+			if !httpServerOpts.isAllowedMethod(methodName) || overridden[methodName] {
+				continue
+			}
+			r := route{
+				server:     grpcServer,
+				endpoint:   "/" + httpServerOpts.endpointNamer(methodName),
+				methodName: methodName,
+				methodFunc: reflect.ValueOf(grpcServer).MethodByName(methodName),
+				streamDesc: httpServerOpts.streamDesc(grpcServer, methodName),
+			}
+			if r.streamDesc == nil && !isUnaryMethod(r.methodFunc.Type()) {
+				// Helper methods and streaming methods without a ServiceDesc are not RPC methods that can be served.
+				httpServerOpts.logger.Debugf("Skipping method %s which is not a unary RPC method: %s", methodName, r.methodFunc.Type())
+				continue
+			}
+			if r.streamDesc == nil {
+				if err := checkUnaryMethod(methodName, r.methodFunc); err != nil {
+					return nil, err
+				}
+			}
+			routes = append(routes, r)
+		}
+	}
+
+	// Added endpoints are bound methods, their server is only known when there is a single one.
+	var addedServer interface{}
+	if len(grpcServers) == 1 {
+		addedServer = grpcServers[0]
+	}
+	for endpoint, method := range httpServerOpts.endpointToMethodMap {
+		methodName := shortMethodName(runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name())
+		if !httpServerOpts.isAllowedMethod(methodName) {
+			continue
+		}
+		r := route{server: addedServer, endpoint: endpoint, methodName: methodName, methodFunc: reflect.ValueOf(method)}
+		if err := checkUnaryMethod(r.methodName, r.methodFunc); err != nil {
+			return nil, err
+		}
+		if err := checkPathParams(r.methodName, messageDescriptor(r.methodFunc.Type().In(1)), pathParamNames(endpoint)); err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+
+	sort.Slice(routes, func(i, j int) bool { return routes[i].endpoint < routes[j].endpoint })
+	// Two methods can be mapped to the same path by the EndpointNamer, AddEndpoints or when serving several servers.
+	for i := 1; i < len(routes); i++ {
+		if routes[i].endpoint == routes[i-1].endpoint {
+			return nil, fmt.Errorf("grpcj: methods %s and %s are both served at %s", routes[i-1].describe(), routes[i].describe(), routes[i].endpoint)
+		}
+	}
+	return routes, nil
+}
+
+// disallowedRoutes returns the endpoints of the RPC methods excluded by AllowedMethods, which would be served without it.
+// The paths that are already served by routes are skipped.
+func disallowedRoutes(grpcServers []interface{}, routes []route, httpServerOpts *serverOpts) []route {
+	if len(httpServerOpts.allowedMethods) == 0 {
+		return nil
+	}
+	served := map[string]bool{}
+	for _, r := range routes {
+		served[r.endpoint] = true
+	}
+	var disallowed []route
+	add := func(r route) {
+		if !served[r.endpoint] && !httpServerOpts.isAllowedMethod(r.methodName) {
+			served[r.endpoint] = true
+			disallowed = append(disallowed, r)
+		}
+	}
+	if httpServerOpts.autoRegistration {
+		for _, grpcServer := range grpcServers {
+			grpcServerType := reflect.TypeOf(grpcServer)
+			for i := 0; i < grpcServerType.NumMethod(); i++ {
+				methodName := grpcServerType.Method(i).Name
+				if httpServerOpts.streamDesc(grpcServer, methodName) == nil && !isUnaryMethod(reflect.ValueOf(grpcServer).Method(i).Type()) {
+					continue
+				}
+				add(route{server: grpcServer, endpoint: "/" + httpServerOpts.endpointNamer(methodName), methodName: methodName})
+			}
+		}
+	}
+	for endpoint, method := range httpServerOpts.endpointToMethodMap {
+		add(route{endpoint: endpoint, methodName: shortMethodName(runtime.FuncForPC(reflect.ValueOf(method).Pointer()).Name())})
+	}
+	sort.Slice(disallowed, func(i, j int) bool { return disallowed[i].endpoint < disallowed[j].endpoint })
+	return disallowed
+}
+
+// disallowedHandler answers the requests to the endpoints of a method excluded by AllowedMethods, see DisallowedMethodCode.
+func disallowedHandler(methodName string, httpServerOpts *serverOpts) http.Handler {
+	if httpServerOpts.disallowedCode == codes.NotFound {
+		return notFoundHandler(httpServerOpts)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSONStatus(w, status.Newf(httpServerOpts.disallowedCode, "method %s is not exposed", methodName), httpServerOpts)
+	})
+}
+
+// allowHeader returns the Allow header of the responses to OPTIONS requests to the endpoint of r.
+func (r route) allowHeader(httpServerOpts *serverOpts) string {
+	var allowed []string
+	for _, method := range r.info(httpServerOpts).HTTPMethods {
+		if !supportedHTTPMethods[method] {
+			continue
+		}
+		allowed = append(allowed, method)
+		if method == http.MethodGet && r.streamDesc == nil {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	return strings.Join(append(allowed, http.MethodOptions), ", ")
+}
+
+// describe returns the name of the method of r for error messages, along with the type of its server when it's known.
+func (r route) describe() string {
+	if r.server == nil {
+		return r.methodName
+	}
+	return fmt.Sprintf("%s of %T", r.methodName, r.server)
+}
+
+// checkUnaryMethod returns an error if a method is not a unary RPC method with supported request and response types.
+func checkUnaryMethod(methodName string, methodFunc reflect.Value) error {
+	methodType := methodFunc.Type()
+	if !isUnaryMethod(methodType) {
+		return fmt.Errorf("grpcj: method %s must be of the form func(context.Context, *Request) (*Response, error), got %s", methodName, methodType)
+	}
+	if responseType := methodType.Out(0); !responseType.Implements(protoMessageType) {
+		return fmt.Errorf("grpcj: response of method %s must be a proto message, got %s", methodName, responseType)
+	}
+	_, err := newMethodPlan(methodName, methodFunc)
+	return err
+}
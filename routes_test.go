@@ -0,0 +1,199 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestRoutes(t *testing.T) {
+	server := &testServer{}
+	routes, err := Routes(server,
+		ServiceDesc(&testServiceDesc),
+		PathPrefix("/api"),
+		AddEndpoints(map[string]interface{}{"/Sum": server.Add}),
+		AllowedHTTPMethods(map[string][]string{"/Sum": {"PUT"}}),
+	)
+	if err != nil {
+		t.Fatalf("Error to list the routes, Error:%s", err)
+	}
+	requestType, responseType := reflect.TypeOf(&addRequest{}), reflect.TypeOf(&addResponse{})
+	want := []RouteInfo{
+		{Path: "/api/Add", Method: "Add", HTTPMethods: []string{"POST", "GET"}, RequestType: requestType, ResponseType: responseType},
+		{Path: "/api/Count", Method: "Count", HTTPMethods: []string{"POST", "GET"}, RequestType: requestType, ResponseType: responseType, ServerStreaming: true},
+		{Path: "/api/Sum", Method: "Add", HTTPMethods: []string{"PUT"}, RequestType: requestType, ResponseType: responseType},
+	}
+	if !reflect.DeepEqual(routes, want) {
+		t.Errorf("Expect: %+v, Got: %+v", want, routes)
+	}
+
+	if _, err := Routes(nil); err == nil {
+		t.Error("Expect an error for a nil server")
+	}
+}
+
+type subtractServer struct{}
+
+func (s *subtractServer) Subtract(ctx context.Context, req *addRequest) (*addResponse, error) {
+	return &addResponse{Sum: req.NumOne - req.NumTwo}, nil
+}
+
+func TestNewServerAll(t *testing.T) {
+	serverHTTP, err := NewServerAll([]interface{}{&testServer{}, &subtractServer{}}, PathPrefix("/api"))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := map[string]string{
+		"/api/Add":      `{"sum":3}`,
+		"/api/Subtract": `{"sum":-1}`,
+	}
+	for path, want := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", path, `{"num_one": 1, "num_two": 2}`))
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Errorf("%s: Expect body: %s, Got: %s", path, want, got)
+		}
+	}
+
+	_, err = NewServerAll([]interface{}{&testServer{}, &subtractServer{}, &testServer{}})
+	if err == nil || !strings.Contains(err.Error(), "/Add") {
+		t.Errorf("Expect an error naming the path served by two servers, Got: %v", err)
+	}
+}
+
+func TestDuplicateEndpoints(t *testing.T) {
+	server := &testServer{}
+	tests := []struct {
+		name    string
+		options []func(*serverOpts)
+		wantErr string
+	}{
+		{"added endpoint", []func(*serverOpts){AddEndpoints(map[string]interface{}{"/Add": server.Add})}, "methods Add of *grpcj.testServer and Add of *grpcj.testServer are both served at /Add"},
+		{"conflicting patterns", []func(*serverOpts){AddEndpoints(map[string]interface{}{"/Sum/{num_one}": server.Add, "/Sum/{num_two}": server.Add})}, "can't serve method Add of *grpcj.testServer at /Sum/"},
+		{"healthcheck", []func(*serverOpts){HealthCheck("/Add", func() error { return nil }, time.Hour)}, "can't serve a healthcheck at /Add"},
+	}
+	for _, test := range tests {
+		_, err := NewServer(server, test.options...)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: Expect an error containing %q, Got: %v", test.name, test.wantErr, err)
+		}
+	}
+}
+
+func TestOverrideDefaultEndpoints(t *testing.T) {
+	server := &testServer{}
+	deprecated := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	serverHTTP, err := NewServer(server,
+		AddEndpoints(map[string]interface{}{"/v1/Add": server.Add, "/sums:add": server.Add}),
+		DeprecatedEndpoint("/v1/Add", deprecated, deprecated.AddDate(0, 6, 0)),
+		DeprecatedEndpoint("/sums:add", deprecated, time.Time{}),
+		OverrideDefaultEndpoints(true),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		path        string
+		wantBody    string
+		deprecation string
+		sunset      string
+	}{
+		{"/Add", "404 page not found", "", ""},
+		{"/v1/Add", `{"sum":3}`, "@1704067200", "Mon, 01 Jul 2024 00:00:00 GMT"},
+		{"/sums:add", `{"sum":3}`, "@1704067200", ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, `{"num_one": 1, "num_two": 2}`))
+		if got := strings.TrimSpace(rec.Body.String()); !strings.Contains(got, test.wantBody) {
+			t.Errorf("%s: Expect body: %s, Got: %s", test.path, test.wantBody, got)
+		}
+		if got := rec.Header().Get("Deprecation"); got != test.deprecation {
+			t.Errorf("%s: Expect Deprecation: %q, Got: %q", test.path, test.deprecation, got)
+		}
+		if got := rec.Header().Get("Sunset"); got != test.sunset {
+			t.Errorf("%s: Expect Sunset: %q, Got: %q", test.path, test.sunset, got)
+		}
+	}
+}
+
+func TestDisableAutoRegistration(t *testing.T) {
+	server := &testServer{}
+	options := []func(*serverOpts){
+		AddEndpoints(map[string]interface{}{"/sums:add": server.Add}),
+		HealthCheck("/readyz", func() error { return nil }, time.Hour),
+		DisableAutoRegistration(),
+	}
+	routes, err := Routes(server, options...)
+	if err != nil {
+		t.Fatalf("Error to list the routes, Error:%s", err)
+	}
+	if len(routes) != 1 || routes[0].Path != "/sums:add" {
+		t.Errorf("Expect only the added endpoint, Got: %+v", routes)
+	}
+
+	serverHTTP, err := NewServer(server, options...)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	defer serverHTTP.Shutdown(context.Background())
+	tests := map[string]int{"/Add": 404, "/sums:add": 200, "/readyz": 200}
+	for path, wantStatus := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", path, `{"num_one": 1, "num_two": 2}`))
+		if rec.Code != wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", path, wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	server, subtract := &testServer{}, &subtractServer{}
+	serverHTTP, err := NewServerAll([]interface{}{server, subtract},
+		AllowedMethods([]interface{}{server.Add}),
+		AddEndpoints(map[string]interface{}{"/sums:add": server.Add, "/sums:subtract": subtract.Subtract}),
+	)
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := map[string]int{"/Add": 200, "/sums:add": 200, "/Subtract": 404, "/sums:subtract": 404}
+	for path, wantStatus := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", path, `{"num_one": 1, "num_two": 2}`))
+		if rec.Code != wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", path, wantStatus, rec.Code)
+		}
+	}
+}
+
+func TestDisallowedMethodCode(t *testing.T) {
+	server, subtract := &testServer{}, &subtractServer{}
+	tests := []struct {
+		options    []func(*serverOpts)
+		wantStatus int
+		wantBody   string
+	}{
+		{nil, http.StatusNotFound, `{"code":"NOT_FOUND","message":"no endpoint for /Subtract","details":[]}`},
+		{[]func(*serverOpts){DisallowedMethodCode(codes.PermissionDenied)}, http.StatusForbidden, `{"code":"PERMISSION_DENIED","message":"method Subtract is not exposed","details":[]}`},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServerAll([]interface{}{server, subtract}, append(test.options, AllowedMethods([]interface{}{server.Add}))...)
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Subtract", `{"num_one": 1, "num_two": 2}`))
+		if rec.Code != test.wantStatus || rec.Body.String() != test.wantBody {
+			t.Errorf("Expect %d %s, Got: %d %s", test.wantStatus, test.wantBody, rec.Code, rec.Body.String())
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != defaultContentType {
+			t.Errorf("Expect Content-Type: application/json, Got: %s", contentType)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type addRequest struct {
+	NumOne int64 `protobuf:"varint,1,opt,name=num_one,json=numOne,proto3" json:"num_one,omitempty"`
+	NumTwo int64 `protobuf:"varint,2,opt,name=num_two,json=numTwo,proto3" json:"num_two,omitempty"`
+}
+
+func (m *addRequest) Reset()         { *m = addRequest{} }
+func (m *addRequest) String() string { return proto.CompactTextString(m) }
+func (*addRequest) ProtoMessage()    {}
+
+type addResponse struct {
+	Sum int64 `protobuf:"varint,1,opt,name=sum,proto3" json:"sum,omitempty"`
+}
+
+func (m *addResponse) Reset()         { *m = addResponse{} }
+func (m *addResponse) String() string { return proto.CompactTextString(m) }
+func (*addResponse) ProtoMessage()    {}
+
+type testServer struct {
+	err   error
+	ctx   context.Context
+	delay time.Duration
+}
+
+func (s *testServer) Add(ctx context.Context, req *addRequest) (*addResponse, error) {
+	s.ctx = ctx
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &addResponse{Sum: req.NumOne + req.NumTwo}, nil
+}
+
+// serveMethod calls the named method of grpcServer through grpcjHandler and returns the recorded response.
+func serveMethod(grpcServer interface{}, methodName string, req *http.Request, options ...func(*serverOpts)) *httptest.ResponseRecorder {
+	httpServerOpts := applyOptions(options)
+	methodFunc := reflect.ValueOf(grpcServer).MethodByName(methodName)
+	rec := httptest.NewRecorder()
+	handler, err := grpcjHandler(grpcServer, "/"+methodName, methodName, methodFunc, httpServerOpts)
+	if err != nil {
+		panic(err)
+	}
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func newJSONRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// Count sends a response for every number from 1 to NumOne, it fails after NumTwo responses when NumTwo is set.
+func (s *testServer) Count(req *addRequest, stream TestService_CountServer) error {
+	for i := int64(1); i <= req.NumOne; i++ {
+		if req.NumTwo > 0 && i > req.NumTwo {
+			return status.Error(codes.ResourceExhausted, "too many")
+		}
+		if err := stream.Send(&addResponse{Sum: i}); err != nil {
+			return err
+		}
+		time.Sleep(s.delay)
+	}
+	return nil
+}
+
+// The following mirrors the code generated by protoc-gen-go-grpc for a server-streaming method.
+
+type TestService_CountServer interface {
+	Send(*addResponse) error
+	grpc.ServerStream
+}
+
+type testServiceCountServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServiceCountServer) Send(m *addResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _TestService_Count_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(addRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(*testServer).Count(m, &testServiceCountServer{stream})
+}
+
+var testServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.TestService",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Add"}},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Count",
+			Handler:       _TestService_Count_Handler,
+			ServerStreams: true,
+		},
+	},
+}
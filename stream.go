@@ -0,0 +1,292 @@
+package grpcj
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceDesc allows serving the streaming RPC methods of a service. Pass in the generated service descriptor
+// (e.g. ServiceDesc(&pb.MyService_ServiceDesc)), it is used to wrap the HTTP request and response in the generated stream types.
+// Server-streaming methods are served as newline-delimited JSON: the request is decoded the same way as for unary methods
+// and each message sent by the method is written as one JSON object followed by the marshaler's delimiter and flushed to the client.
+// When the request has an "Accept: text/event-stream" header, the messages are sent as Server-Sent Events instead
+// so they can be consumed by browsers with EventSource, see SSEHeartbeat.
+// Client-streaming methods receive a newline-delimited JSON body, each line is decoded into one request message
+// and the response sent with SendAndClose is written like the response of a unary method.
+// Bidirectional-streaming methods are served over WebSocket when the WebSocket option is enabled.
+// Streaming methods are not subject to the Timeout option, they run until they return or the client disconnects.
+//
+// With ServeAll, ServiceDesc can be passed for each server, a descriptor applies to the servers that implement its HandlerType.
+func ServiceDesc(desc *grpc.ServiceDesc) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.serviceDescs = append(s.serviceDescs, desc)
+	}
+}
+
+// serviceDesc returns the service descriptor that applies to grpcServer, or nil if there is none.
+func (s *serverOpts) serviceDesc(grpcServer interface{}) *grpc.ServiceDesc {
+	if grpcServer == nil {
+		return nil
+	}
+	for _, desc := range s.serviceDescs {
+		if desc.HandlerType == nil || reflect.TypeOf(grpcServer).Implements(reflect.TypeOf(desc.HandlerType).Elem()) {
+			return desc
+		}
+	}
+	return nil
+}
+
+// SSEHeartbeat allows setting the interval at which a comment (": ping") is sent on Server-Sent Events streams to keep the connection alive.
+// Default is 15 seconds, an interval of 0 disables the heartbeat.
+// Each message is sent as "data: <json>" and an error after the first message is sent as an "error" event with a JSON body.
+func SSEHeartbeat(interval time.Duration) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.sseHeartbeat = interval
+	}
+}
+
+// streamDesc returns the stream descriptor of methodName of grpcServer if it is a server-streaming or a client-streaming method,
+// or a bidirectional-streaming method when WebSocket is enabled.
+func (s *serverOpts) streamDesc(grpcServer interface{}, methodName string) *grpc.StreamDesc {
+	serviceDesc := s.serviceDesc(grpcServer)
+	if serviceDesc == nil {
+		return nil
+	}
+	for i := range serviceDesc.Streams {
+		streamDesc := &serviceDesc.Streams[i]
+		if streamDesc.StreamName != methodName {
+			continue
+		}
+		if streamDesc.ServerStreams != streamDesc.ClientStreams || (s.webSocket && streamDesc.ServerStreams) {
+			return streamDesc
+		}
+	}
+	return nil
+}
+
+// delimiter returns the delimiter written after each streamed message, marshalers can define their own by implementing Delimiter() []byte.
+func delimiter(marshaler JSONPBMarshaler) []byte {
+	if d, ok := marshaler.(interface{ Delimiter() []byte }); ok {
+		return d.Delimiter()
+	}
+	return []byte("\n")
+}
+
+// httpServerStream is a grpc.ServerStream that receives the request message from an HTTP request
+// and sends the response messages as newline-delimited JSON or as Server-Sent Events.
+type httpServerStream struct {
+	*serverTransportStream
+	ctx            context.Context
+	w              http.ResponseWriter
+	r              *http.Request
+	httpServerOpts *serverOpts
+	methodName     string
+	marshaler      JSONPBMarshaler
+	sse            bool
+	received       bool
+	recvErr        error
+	validationErr  error
+
+	// mu guards writes to w, which happen concurrently when heartbeats are sent.
+	mu   sync.Mutex
+	sent bool
+}
+
+func (s *httpServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *httpServerStream) SetTrailer(md metadata.MD) {
+	s.serverTransportStream.SetTrailer(md)
+}
+
+// RecvMsg decodes the HTTP request into m, server-streaming methods receive exactly one message.
+func (s *httpServerStream) RecvMsg(m interface{}) error {
+	if s.received {
+		return io.EOF
+	}
+	s.received = true
+	if err := decodeRequest(s.r, m, s.httpServerOpts); err != nil {
+		s.recvErr = err
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.httpServerOpts.validate(s.ctx, s.methodName, m); err != nil {
+		s.validationErr = err
+		return err
+	}
+	return nil
+}
+
+// sendHeaders writes the response headers before the first message of the stream. s.mu must be held.
+func (s *httpServerStream) sendHeaders() {
+	if s.sent {
+		return
+	}
+	s.sent = true
+	if s.httpServerOpts.metadataToHeader != nil {
+		s.writeHeaders(s.w.Header(), s.httpServerOpts.metadataToHeader)
+	}
+	if s.sse {
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		s.w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// write sends one frame of the stream to the client. For Server-Sent Events the data is sent as the given event type,
+// otherwise it is followed by the marshaler's delimiter.
+func (s *httpServerStream) write(event string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendHeaders()
+	frame := getBuffer()
+	defer putBuffer(frame)
+	if s.sse {
+		if event != "" {
+			frame.WriteString("event: " + event + "\n")
+		}
+		frame.WriteString("data: ")
+		frame.Write(data)
+		frame.WriteString("\n\n")
+	} else {
+		frame.Write(data)
+		frame.Write(delimiter(s.marshaler))
+	}
+	if _, err := s.w.Write(frame.Bytes()); err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+func (s *httpServerStream) SendMsg(m interface{}) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := marshalerFor(s.marshaler, m).Marshal(buf, m); err != nil {
+		return err
+	}
+	return s.write("", buf.Bytes())
+}
+
+// heartbeat sends Server-Sent Events comments at the given interval until done is closed, to keep the connection alive.
+func (s *httpServerStream) heartbeat(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			s.sendHeaders()
+			s.w.Write([]byte(": ping\n\n"))
+			if flusher, ok := s.w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// acceptsEventStream reports whether the client asked for Server-Sent Events.
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+func serverStreamHandler(endpoint string, grpcServer interface{}, streamDesc *grpc.StreamDesc, httpServerOpts *serverOpts) http.HandlerFunc {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !httpServerOpts.isAllowedHTTPMethod(endpoint, r.Method) {
+			writeMethodNotAllowed(w, endpoint, httpServerOpts)
+			return
+		}
+
+		limitRequestBody(w, r, httpServerOpts)
+		ctx := incomingContext(r.Context(), r, httpServerOpts)
+		stream := &httpServerStream{
+			serverTransportStream: &serverTransportStream{method: endpoint},
+			w:                     w,
+			r:                     r,
+			httpServerOpts:        httpServerOpts,
+			methodName:            streamDesc.StreamName,
+			marshaler:             httpServerOpts.requestMarshaler(r),
+			sse:                   acceptsEventStream(r),
+		}
+		stream.ctx = grpc.NewContextWithServerTransportStream(ctx, stream.serverTransportStream)
+
+		stopHeartbeat := func() {}
+		if stream.sse && httpServerOpts.sseHeartbeat > 0 {
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				stream.heartbeat(httpServerOpts.sseHeartbeat, done)
+			}()
+			var once sync.Once
+			stopHeartbeat = func() {
+				once.Do(func() {
+					close(done)
+					wg.Wait()
+				})
+			}
+			defer stopHeartbeat()
+		}
+
+		start := time.Now()
+		err := contextError(streamDesc.Handler(grpcServer, stream))
+		httpServerOpts.metrics.observeRPC(streamDesc.StreamName, status.Code(err), time.Since(start))
+		// The heartbeat must stop before the final write, the ResponseWriter can't be used concurrently or after the handler returns.
+		stopHeartbeat()
+
+		if err == nil {
+			// Streams that didn't send any messages still respond with an empty stream.
+			stream.sendHeaders()
+			return
+		}
+		if !stream.sent && stream.recvErr != nil {
+			writeDecodeError(w, stream.recvErr, httpServerOpts)
+			return
+		}
+		// Invalid requests are answered with a JSON error like with unary methods.
+		if !stream.sent && stream.validationErr != nil {
+			writeJSONStatus(w, status.Convert(stream.validationErr), httpServerOpts)
+			return
+		}
+		if !stream.sent {
+			if httpServerOpts.metadataToHeader != nil {
+				stream.writeHeaders(w.Header(), httpServerOpts.metadataToHeader)
+			}
+			writeError(stream.ctx, w, r, err, httpServerOpts)
+			return
+		}
+
+		// The status code has already been sent, so the error is written as the last message of the stream.
+		st := status.Convert(err)
+		body := ErrorBody{Code: codeName(st.Code()), Message: st.Message(), Details: []json.RawMessage{}}
+		if stream.sse {
+			resp, _ := json.Marshal(body)
+			stream.write("error", resp)
+			return
+		}
+		resp, _ := json.Marshal(struct {
+			Error ErrorBody `json:"error"`
+		}{body})
+		stream.write("", resp)
+	})
+	return handler
+}
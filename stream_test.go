@@ -0,0 +1,87 @@
+package grpcj
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerStream(t *testing.T) {
+	serverHTTP, err := NewServer(&testServer{}, ServiceDesc(&testServiceDesc))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	tests := []struct {
+		req        *http.Request
+		wantStatus int
+		wantType   string
+		wantBody   string
+	}{
+		{newJSONRequest("POST", "/Count", `{"num_one": 3}`), http.StatusOK, "application/x-ndjson", "{\"sum\":1}\n{\"sum\":2}\n{\"sum\":3}\n"},
+		{newJSONRequest("GET", "/Count?num_one=2", ""), http.StatusOK, "application/x-ndjson", "{\"sum\":1}\n{\"sum\":2}\n"},
+		{newJSONRequest("POST", "/Count", `{"num_one": 3, "num_two": 1}`), http.StatusOK, "application/x-ndjson", "{\"sum\":1}\n{\"error\":{\"code\":\"RESOURCE_EXHAUSTED\",\"message\":\"too many\",\"details\":[]}}\n"},
+		{newJSONRequest("POST", "/Count", `{"num_one": 0}`), http.StatusOK, "application/x-ndjson", ""},
+		{newJSONRequest("POST", "/Count", `{"num_one": "x"}`), http.StatusBadRequest, defaultContentType, ""},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, test.req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.req.URL, test.wantStatus, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != test.wantType {
+			t.Errorf("%s: Expect Content-Type: %s, Got: %s", test.req.URL, test.wantType, got)
+		}
+		if test.wantStatus == http.StatusOK && rec.Body.String() != test.wantBody {
+			t.Errorf("%s: Expect: %q, Got: %q", test.req.URL, test.wantBody, rec.Body.String())
+		}
+	}
+}
+
+func TestServerStreamSSE(t *testing.T) {
+	serverHTTP, err := NewServer(&testServer{}, ServiceDesc(&testServiceDesc), SSEHeartbeat(0))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	tests := []struct {
+		body     string
+		wantBody string
+	}{
+		{`{"num_one": 2}`, "data: {\"sum\":1}\n\ndata: {\"sum\":2}\n\n"},
+		{`{"num_one": 2, "num_two": 1}`, "data: {\"sum\":1}\n\nevent: error\ndata: {\"code\":\"RESOURCE_EXHAUSTED\",\"message\":\"too many\",\"details\":[]}\n\n"},
+	}
+	for _, test := range tests {
+		req := newJSONRequest("POST", "/Count", test.body)
+		req.Header.Set("Accept", "text/event-stream")
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("Expect Content-Type: text/event-stream, Got: %s", got)
+		}
+		if rec.Body.String() != test.wantBody {
+			t.Errorf("Expect: %q, Got: %q", test.wantBody, rec.Body.String())
+		}
+	}
+}
+
+func TestServerStreamSSEHeartbeat(t *testing.T) {
+	serverHTTP, err := NewServer(&testServer{delay: 50 * time.Millisecond}, ServiceDesc(&testServiceDesc), SSEHeartbeat(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+
+	req := newJSONRequest("POST", "/Count", `{"num_one": 2}`)
+	req.Header.Set("Accept", "text/event-stream")
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), ": ping\n\n") {
+		t.Errorf("Expect heartbeat comments in the stream, Got: %q", rec.Body.String())
+	}
+	if !strings.HasPrefix(rec.Body.String(), "data: {\"sum\":1}\n\n") {
+		t.Errorf("Expect the stream to start with the first message, Got: %q", rec.Body.String())
+	}
+}
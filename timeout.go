@@ -0,0 +1,70 @@
+package grpcj
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GRPCTimeoutHeader is the header used by gRPC and gRPC-Web clients to send the timeout of a request, e.g. "500m" for 500 milliseconds.
+const GRPCTimeoutHeader = "Grpc-Timeout"
+
+// ClientTimeouts allows clients to shorten the timeout of unary requests with a Grpc-Timeout header, which has the gRPC format:
+// a positive integer of at most 8 digits followed by a unit, H for hours, M for minutes, S for seconds, m for milliseconds,
+// u for microseconds or n for nanoseconds (e.g. "Grpc-Timeout: 2S"). The timeout of the method, set by the Timeout and
+// MethodTimeout options, is the maximum: a longer client timeout is ignored. Requests with an invalid header are rejected with a 400 INVALID_ARGUMENT JSON error.
+// It is disabled by default.
+func ClientTimeouts(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.clientTimeouts = enabled
+	}
+}
+
+// requestTimeout returns the timeout of a request to a method with the given timeout, shortened by the Grpc-Timeout header
+// when the ClientTimeouts option is enabled.
+func (s *serverOpts) requestTimeout(r *http.Request, methodTimeout time.Duration) (time.Duration, error) {
+	value := r.Header.Get(GRPCTimeoutHeader)
+	if !s.clientTimeouts || value == "" {
+		return methodTimeout, nil
+	}
+	timeout, err := parseGRPCTimeout(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout < methodTimeout {
+		return timeout, nil
+	}
+	return methodTimeout, nil
+}
+
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGRPCTimeout parses the value of a Grpc-Timeout header.
+func parseGRPCTimeout(value string) (time.Duration, error) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, errors.New("invalid Grpc-Timeout header: " + strconv.Quote(value))
+	}
+	unit, ok := grpcTimeoutUnits[value[len(value)-1]]
+	if !ok {
+		return 0, errors.New("invalid Grpc-Timeout header unit: " + strconv.Quote(value))
+	}
+	amount, err := strconv.ParseUint(value[:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid Grpc-Timeout header: " + strconv.Quote(value))
+	}
+	// 8 digits of hours don't fit in a time.Duration, such timeouts are longer than any method timeout anyway.
+	if amount > uint64(maxDuration/unit) {
+		return maxDuration, nil
+	}
+	return time.Duration(amount) * unit, nil
+}
+
+const maxDuration = time.Duration(1<<63 - 1)
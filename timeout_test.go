@@ -0,0 +1,90 @@
+package grpcj
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sleepServer waits for the request context to be done, like a method calling a slow dependency.
+type sleepServer struct{}
+
+func (s *sleepServer) Sleep(ctx context.Context, req *addRequest) (*addResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClientTimeouts(t *testing.T) {
+	tests := []struct {
+		header       string
+		enabled      bool
+		wantStatus   int
+		wantDeadline time.Duration
+	}{
+		{"", true, http.StatusOK, time.Minute},
+		{"100m", true, http.StatusOK, 100 * time.Millisecond},
+		{"2S", true, http.StatusOK, 2 * time.Second},
+		{"2H", true, http.StatusOK, time.Minute},
+		{"99999999H", true, http.StatusOK, time.Minute},
+		{"100m", false, http.StatusOK, time.Minute},
+		{"abc", false, http.StatusOK, time.Minute},
+		{"abc", true, http.StatusBadRequest, 0},
+		{"10s", true, http.StatusBadRequest, 0},
+		{"123456789m", true, http.StatusBadRequest, 0},
+		{"-1S", true, http.StatusBadRequest, 0},
+	}
+	for _, test := range tests {
+		server := &testServer{}
+		serverHTTP, err := NewServer(server, Timeout(time.Minute), ClientTimeouts(test.enabled))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		req := newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`)
+		if test.header != "" {
+			req.Header.Set(GRPCTimeoutHeader, test.header)
+		}
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		serverHTTP.Handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%q (enabled: %t): Expect status: %d, Got: %d", test.header, test.enabled, test.wantStatus, rec.Code)
+			continue
+		}
+		if test.wantStatus != http.StatusOK {
+			var body ErrorBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "INVALID_ARGUMENT" {
+				t.Errorf("%q (enabled: %t): Expect an INVALID_ARGUMENT JSON error, Got: %s", test.header, test.enabled, rec.Body.String())
+			}
+			continue
+		}
+		deadline, ok := server.ctx.Deadline()
+		if got := deadline.Sub(start); !ok || got > test.wantDeadline+time.Second || got < test.wantDeadline-time.Second {
+			t.Errorf("%q (enabled: %t): Expect a deadline in %s, Got: %s", test.header, test.enabled, test.wantDeadline, got)
+		}
+	}
+}
+
+func TestDeadlineExceeded(t *testing.T) {
+	serverHTTP, err := NewServer(&sleepServer{}, Timeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	rec := httptest.NewRecorder()
+	serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Sleep", `{}`))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expect status: %d, Got: %d", http.StatusGatewayTimeout, rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != defaultContentType {
+		t.Errorf("Expect Content-Type: %s, Got: %s", defaultContentType, got)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expect a JSON error body, Got: %s", rec.Body.String())
+	}
+	if body.Code != "DEADLINE_EXCEEDED" {
+		t.Errorf("Expect code: DEADLINE_EXCEEDED, Got: %s", body.Code)
+	}
+}
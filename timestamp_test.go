@@ -2,10 +2,12 @@ package grpcj
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/zang-cloud/grpc-json/jsonpb"
 )
 
 type SampleTimestampContainingStruct struct {
@@ -42,3 +44,45 @@ func TestTimeStampMarshaling(t *testing.T) {
 	}
 
 }
+
+type timestampMessage struct {
+	Time *timestamp.Timestamp `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (m *timestampMessage) Reset()         { *m = timestampMessage{} }
+func (m *timestampMessage) String() string { return m.Time.String() }
+func (*timestampMessage) ProtoMessage()    {}
+
+func TestTimestampFormat(t *testing.T) {
+	specDatetime := time.Date(1986, time.March, 10, 5, 5, 5, 5000000, time.UTC)
+	instance := timestampMessage{Time: &timestamp.Timestamp{Seconds: specDatetime.Unix(), Nanos: int32(specDatetime.Nanosecond())}}
+	tests := []struct {
+		format    jsonpb.TimestampFormat
+		expect    string
+		wantNanos int32
+	}{
+		{jsonpb.TimestampRFC3339Nano, `{"time":"1986-03-10T05:05:05.005Z"}`, 5000000},
+		{jsonpb.TimestampRFC3339, `{"time":"1986-03-10T05:05:05Z"}`, 0},
+		{jsonpb.TimestampUnixMillis, `{"time":510815105005}`, 5000000},
+	}
+	for _, test := range tests {
+		marshaler := &jsonpb.Marshaler{TimestampFormat: test.format}
+		res, err := marshaler.MarshalToString(&instance)
+		if err != nil {
+			t.Errorf("Format %d: Error to Marshal the Structure, Error:%s", test.format, err)
+			continue
+		}
+		if res != test.expect {
+			t.Errorf("Format %d: Expect: %s, Got: %s", test.format, test.expect, res)
+		}
+
+		var got timestampMessage
+		if err := DefaultUnmarshaler.Unmarshal(strings.NewReader(res), &got); err != nil {
+			t.Errorf("Format %d: Error to Unmarshal %s, Error:%s", test.format, res, err)
+			continue
+		}
+		if got.Time.GetSeconds() != specDatetime.Unix() || got.Time.GetNanos() != test.wantNanos {
+			t.Errorf("Format %d: Expect: %d.%09d, Got: %d.%09d", test.format, specDatetime.Unix(), test.wantNanos, got.Time.GetSeconds(), got.Time.GetNanos())
+		}
+	}
+}
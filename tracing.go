@@ -0,0 +1,50 @@
+package grpcj
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/status"
+)
+
+// instrumentationName identifies grpc-json as the instrumentation library of its OpenTelemetry spans and metrics.
+const instrumentationName = "github.com/zang-cloud/grpc-json"
+
+// Tracing enables OpenTelemetry tracing of the unary RPC methods with the spans of the given TracerProvider.
+// The trace of the W3C traceparent and tracestate headers of the request is continued, or a new one is started without them,
+// with a server span around the call of the method, including the UnaryInterceptors. The span is named after the gRPC
+// full method name without the leading / (e.g. "calculator.Calculator/Add", or "Add" without a ServiceDesc) and records
+// the gRPC status code of the call. It is attached to the context passed to the method, so that instrumented clients
+// used by the method propagate the trace downstream.
+func Tracing(tracerProvider trace.TracerProvider) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.tracer = tracerProvider.Tracer(instrumentationName)
+	}
+}
+
+// startSpan starts the server span of a call to the RPC method fullMethod, continuing the trace of the headers of r.
+func (s *serverOpts) startSpan(ctx context.Context, r *http.Request, fullMethod string) (context.Context, trace.Span) {
+	ctx = propagation.TraceContext{}.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	name := strings.TrimPrefix(fullMethod, "/")
+	return s.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.method", name[strings.LastIndex(name, "/")+1:]),
+		attribute.String("http.request.method", r.Method),
+		attribute.String("url.path", r.URL.Path),
+	))
+}
+
+// endSpan records the gRPC status code of err on span and ends it.
+func endSpan(span trace.Span, err error) {
+	st := status.Convert(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(st.Code())))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, st.Message())
+	}
+	span.End()
+}
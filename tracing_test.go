@@ -0,0 +1,64 @@
+package grpcj
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	server := &testServer{}
+	req := newJSONRequest("POST", "/Add", `{"num_one": 1, "num_two": 2}`)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	serveMethod(server, "Add", req, Tracing(tracerProvider), ServiceDesc(&testServiceDesc))
+
+	server.err = status.Error(codes.NotFound, "no such sum")
+	serveMethod(server, "Add", newJSONRequest("POST", "/Add", `{}`), Tracing(tracerProvider))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expect 2 spans, Got: %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != testServiceDesc.ServiceName+"/Add" || span.SpanKind() != trace.SpanKindServer {
+		t.Errorf("Expect a server span named after the method, Got: %s %s", span.Name(), span.SpanKind())
+	}
+	if traceID := span.Parent().TraceID().String(); traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || !span.Parent().IsRemote() {
+		t.Errorf("Expect the trace of the traceparent header to be continued, Got: %s", traceID)
+	}
+	if span.SpanContext().TraceID() != span.Parent().TraceID() {
+		t.Errorf("Expect the span to be in the incoming trace")
+	}
+	if !hasAttribute(span.Attributes(), attribute.Int("rpc.grpc.status_code", 0)) || span.Status().Code != otelcodes.Unset {
+		t.Errorf("Expect an OK status code, Got: %v %v", span.Attributes(), span.Status())
+	}
+
+	span = spans[1]
+	if span.Name() != "Add" || span.Parent().IsValid() {
+		t.Errorf("Expect a new trace, Got: %s with parent %v", span.Name(), span.Parent())
+	}
+	if !hasAttribute(span.Attributes(), attribute.Int("rpc.grpc.status_code", int(codes.NotFound))) || span.Status().Code != otelcodes.Error {
+		t.Errorf("Expect a NotFound status code, Got: %v %v", span.Attributes(), span.Status())
+	}
+	if got := trace.SpanContextFromContext(server.ctx); got.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("Expect the span in the context of the method, Got: %s", got.SpanID())
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attributes {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
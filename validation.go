@@ -0,0 +1,115 @@
+package grpcj
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ValidateRequests allows validating the request messages that have a Validate() error or ValidateAll() error method,
+// such as the ones generated by protoc-gen-validate, before the RPC method is called. ValidateAll is preferred when both exist
+// so all the violations are reported at once. A request that fails validation is answered with a 400 and a JSON body in the
+// format of JSONErrors, with a google.rpc.BadRequest detail listing the field violations when the error reports them:
+//
+//	{"code":"INVALID_ARGUMENT","message":"invalid AddRequest.NumOne: value must be greater than 0",
+//		"details":[{"field_violations":[{"field":"num_one","description":"value must be greater than 0"}]}]}
+func ValidateRequests(validate bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.validateRequests = validate
+	}
+}
+
+// Validator allows defining a function that validates the request messages after they are decoded and before the RPC method is called,
+// to centralize business rules or schema checks. It receives the name of the RPC method (e.g. "Add") so the validation can be method-specific.
+// When it returns an error the method is not called and the error is written in the format of JSONErrors. The HTTP status is derived
+// from the gRPC status of the error, so returning status.Error(codes.PermissionDenied, "...") results in a 403, and errors that don't
+// carry a gRPC status result in a 400. Validator can be passed multiple times, the validators are called in order until one fails.
+func Validator(validator func(ctx context.Context, method string, msg proto.Message) error) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.validators = append(s.validators, validator)
+	}
+}
+
+// validate validates a decoded request message according to the ValidateRequests and Validator options.
+// The returned error has a gRPC status, InvalidArgument unless a validator returned a status error.
+func (s *serverOpts) validate(ctx context.Context, methodName string, msg interface{}) error {
+	if s.validateRequests {
+		if err := validateRequest(msg); err != nil {
+			return err
+		}
+	}
+	pb, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+	for _, validator := range s.validators {
+		if err := validator(ctx, methodName, pb); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return err
+			}
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	return nil
+}
+
+// fieldError is implemented by the field validation errors generated by protoc-gen-validate.
+type fieldError interface {
+	error
+	Field() string
+	Reason() string
+}
+
+// validateRequest calls the ValidateAll or Validate method of msg, a failure is returned as an InvalidArgument status error.
+func validateRequest(msg interface{}) error {
+	var err error
+	switch v := msg.(type) {
+	case interface{ ValidateAll() error }:
+		err = v.ValidateAll()
+	case interface{ Validate() error }:
+		err = v.Validate()
+	default:
+		return nil
+	}
+	if err == nil {
+		return nil
+	}
+
+	st := status.New(codes.InvalidArgument, err.Error())
+	if violations := fieldViolations("", err); len(violations) > 0 {
+		if detailed, detailsErr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); detailsErr == nil {
+			st = detailed
+		}
+	}
+	return st.Err()
+}
+
+// fieldViolations returns the field violations reported by a protoc-gen-validate error. The errors of embedded messages
+// are flattened with the path of the field (e.g. "address.zip_code"), multiple errors returned by ValidateAll are all included.
+func fieldViolations(prefix string, err error) []*errdetails.BadRequest_FieldViolation {
+	if multi, ok := err.(interface{ AllErrors() []error }); ok {
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, err := range multi.AllErrors() {
+			violations = append(violations, fieldViolations(prefix, err)...)
+		}
+		return violations
+	}
+
+	fe, ok := err.(fieldError)
+	if !ok {
+		return nil
+	}
+	field := fe.Field()
+	if prefix != "" {
+		field = prefix + "." + field
+	}
+	if cause, ok := err.(interface{ Cause() error }); ok && cause.Cause() != nil {
+		if violations := fieldViolations(field, cause.Cause()); len(violations) > 0 {
+			return violations
+		}
+	}
+	return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: fe.Reason()}}
+}
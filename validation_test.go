@@ -0,0 +1,233 @@
+package grpcj
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// pgvFieldError mimics the field validation errors generated by protoc-gen-validate.
+type pgvFieldError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e pgvFieldError) Field() string  { return e.field }
+func (e pgvFieldError) Reason() string { return e.reason }
+func (e pgvFieldError) Cause() error   { return e.cause }
+func (e pgvFieldError) Error() string  { return fmt.Sprintf("invalid %s: %s", e.field, e.reason) }
+
+// pgvMultiError mimics the error returned by the ValidateAll methods generated by protoc-gen-validate.
+type pgvMultiError []error
+
+func (m pgvMultiError) AllErrors() []error { return m }
+func (m pgvMultiError) Error() string      { return fmt.Sprintf("%d validation errors", len(m)) }
+
+type validatedRequest struct {
+	NumOne int64 `protobuf:"varint,1,opt,name=num_one,json=numOne,proto3" json:"num_one,omitempty"`
+	NumTwo int64 `protobuf:"varint,2,opt,name=num_two,json=numTwo,proto3" json:"num_two,omitempty"`
+}
+
+func (m *validatedRequest) Reset()         { *m = validatedRequest{} }
+func (m *validatedRequest) String() string { return proto.CompactTextString(m) }
+func (*validatedRequest) ProtoMessage()    {}
+
+func (m *validatedRequest) ValidateAll() error {
+	var errs pgvMultiError
+	if m.NumOne <= 0 {
+		errs = append(errs, pgvFieldError{field: "num_one", reason: "value must be greater than 0"})
+	}
+	if m.NumTwo < 0 {
+		errs = append(errs, pgvFieldError{field: "limits", reason: "embedded message failed validation",
+			cause: pgvFieldError{field: "num_two", reason: "value must not be negative"}})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+type plainValidatedRequest struct {
+	NumOne int64 `protobuf:"varint,1,opt,name=num_one,json=numOne,proto3" json:"num_one,omitempty"`
+}
+
+func (m *plainValidatedRequest) Reset()         { *m = plainValidatedRequest{} }
+func (m *plainValidatedRequest) String() string { return proto.CompactTextString(m) }
+func (*plainValidatedRequest) ProtoMessage()    {}
+
+func (m *plainValidatedRequest) Validate() error {
+	if m.NumOne == 0 {
+		return errors.New("num_one is required")
+	}
+	return nil
+}
+
+type validatedServer struct{}
+
+func (s *validatedServer) Add(ctx context.Context, req *validatedRequest) (*addResponse, error) {
+	return &addResponse{Sum: req.NumOne + req.NumTwo}, nil
+}
+
+func (s *validatedServer) Check(ctx context.Context, req *plainValidatedRequest) (*addResponse, error) {
+	return &addResponse{Sum: req.NumOne}, nil
+}
+
+func TestValidateRequests(t *testing.T) {
+	tests := []struct {
+		path       string
+		body       string
+		validate   bool
+		wantStatus int
+		wantBody   []string
+	}{
+		{"/Add", `{"num_one": 1, "num_two": 2}`, true, http.StatusOK, []string{`"sum":3`}},
+		{"/Add", `{"num_one": 0, "num_two": -1}`, false, http.StatusOK, []string{`"sum":-1`}},
+		{"/Add", `{"num_one": 0, "num_two": -1}`, true, http.StatusBadRequest, []string{
+			`"code":"INVALID_ARGUMENT"`,
+			`"field":"num_one","description":"value must be greater than 0"`,
+			`"field":"limits.num_two","description":"value must not be negative"`,
+		}},
+		{"/Check", `{}`, true, http.StatusBadRequest, []string{`"message":"num_one is required"`, `"details":[]`}},
+		{"/Check", `{"num_one": 4}`, true, http.StatusOK, []string{`"sum":4`}},
+	}
+	for _, test := range tests {
+		serverHTTP, err := NewServer(&validatedServer{}, ValidateRequests(test.validate))
+		if err != nil {
+			t.Fatalf("Error to create the server, Error:%s", err)
+		}
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, test.body))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s %s: Expect status: %d, Got: %d", test.path, test.body, test.wantStatus, rec.Code)
+		}
+		for _, want := range test.wantBody {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("%s %s: Expect the body to contain %s, Got: %s", test.path, test.body, want, rec.Body.String())
+			}
+		}
+	}
+}
+
+type validatedStreamServer struct{}
+
+// List sends the sum of the request, Collect returns the sum of all the requests.
+func (s *validatedStreamServer) List(req *validatedRequest, stream grpc.ServerStream) error {
+	return stream.SendMsg(&addResponse{Sum: req.NumOne + req.NumTwo})
+}
+
+func (s *validatedStreamServer) Collect(stream grpc.ServerStream) error {
+	var sum int64
+	for {
+		req := new(validatedRequest)
+		if err := stream.RecvMsg(req); err == io.EOF {
+			return stream.SendMsg(&addResponse{Sum: sum})
+		} else if err != nil {
+			return err
+		}
+		sum += req.NumOne + req.NumTwo
+	}
+}
+
+var validatedStreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.ValidatedStreamService",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "List",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := new(validatedRequest)
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*validatedStreamServer).List(req, stream)
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "Collect",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*validatedStreamServer).Collect(stream)
+			},
+			ClientStreams: true,
+		},
+	},
+}
+
+func TestValidateStreamingRequests(t *testing.T) {
+	serverHTTP, err := NewServer(&validatedStreamServer{}, ServiceDesc(&validatedStreamServiceDesc), ValidateRequests(true))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/List", `{"num_one": 0}`},
+		{"/Collect", `{"num_one": 1}` + "\n" + `{"num_one": 0}`},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", test.path, test.body))
+		if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != defaultContentType {
+			t.Errorf("%s: Expect a JSON 400, Got: %d %s", test.path, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		if want := `"field":"num_one","description":"value must be greater than 0"`; !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("%s: Expect the body to contain %s, Got: %s", test.path, want, rec.Body.String())
+		}
+	}
+}
+
+func TestValidator(t *testing.T) {
+	var gotMethods []string
+	validator := func(ctx context.Context, method string, msg proto.Message) error {
+		gotMethods = append(gotMethods, method)
+		req, ok := msg.(*addRequest)
+		if !ok {
+			return fmt.Errorf("unexpected message %T", msg)
+		}
+		switch {
+		case req.NumOne < 0:
+			return errors.New("num_one must not be negative")
+		case req.NumTwo < 0:
+			return status.Error(codes.PermissionDenied, "negative num_two is reserved")
+		}
+		return nil
+	}
+	serverHTTP, err := NewServer(&testServer{}, Validator(validator))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{`{"num_one": 1, "num_two": 2}`, http.StatusOK, `{"sum":3}`},
+		{`{"num_one": -1, "num_two": 2}`, http.StatusBadRequest, `{"code":"INVALID_ARGUMENT","message":"num_one must not be negative","details":[]}`},
+		{`{"num_one": 1, "num_two": -2}`, http.StatusForbidden, `{"code":"PERMISSION_DENIED","message":"negative num_two is reserved","details":[]}`},
+	}
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		serverHTTP.Handler.ServeHTTP(rec, newJSONRequest("POST", "/Add", test.body))
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: Expect status: %d, Got: %d", test.body, test.wantStatus, rec.Code)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != test.wantBody {
+			t.Errorf("%s: Expect body: %s, Got: %s", test.body, test.wantBody, got)
+		}
+	}
+	if len(gotMethods) != len(tests) || gotMethods[0] != "Add" {
+		t.Errorf("Expect the validator to be called with the method name for every request, Got: %v", gotMethods)
+	}
+}
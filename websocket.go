@@ -0,0 +1,203 @@
+package grpcj
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// WebSocket allows serving the bidirectional-streaming RPC methods of the services passed with ServiceDesc over WebSocket.
+// The endpoint of a method accepts WebSocket handshakes (GET requests with an "Upgrade: websocket" header),
+// each text or binary frame sent by the client is decoded into one request message and each message sent by the method
+// is written as one JSON text frame.
+//
+// Closing the connection from the client ends the request stream: Recv returns io.EOF and the context of the method is canceled.
+// When the method returns, the connection is closed. If it returns an error, a final {"error": {...}} frame with the same body
+// as JSON errors is sent before closing. Frames that can't be decoded fail Recv with codes.InvalidArgument
+// and frames larger than MaxRequestBodySize fail it with codes.ResourceExhausted.
+//
+// Handshakes without an Origin header, from the same host or from an origin allowed by CORS are accepted,
+// other origins are rejected with a 403 and requests that are not WebSocket handshakes are answered with a 426.
+// The response headers are sent with the handshake, before the method runs, so the metadata sent by the method is not written to the response.
+// Middleware that wraps the http.ResponseWriter must implement http.Hijacker or Unwrap() http.ResponseWriter.
+func WebSocket(enabled bool) func(*serverOpts) {
+	return func(s *serverOpts) {
+		s.webSocket = enabled
+	}
+}
+
+// isWebSocketUpgrade reports whether r is a WebSocket handshake.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// checkWebSocketOrigin accepts handshakes without an Origin header, from the same host, or from an origin allowed by CORS.
+func (s *serverOpts) checkWebSocketOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	config.Origin = u
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	if s.cors != nil && s.cors.allowOrigin(origin, false) != "" {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// hijacker makes the connection of a ResponseWriter wrapped by middleware available to the WebSocket server,
+// which requires an http.Hijacker.
+type hijacker struct {
+	http.ResponseWriter
+}
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(h.ResponseWriter).Hijack()
+}
+
+// webSocketStream is a grpc.ServerStream that receives the request messages from the frames of a WebSocket connection
+// and sends the response messages as JSON frames.
+type webSocketStream struct {
+	*serverTransportStream
+	ctx            context.Context
+	conn           *websocket.Conn
+	httpServerOpts *serverOpts
+	methodName     string
+	marshaler      JSONPBMarshaler
+	frames         chan []byte
+	frame          int
+	// readErr is the error that ended the read loop, it is set before frames is closed.
+	readErr error
+
+	// mu guards writes to conn, which can happen concurrently when the method sends from several goroutines.
+	mu sync.Mutex
+}
+
+func (s *webSocketStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *webSocketStream) SetTrailer(md metadata.MD) {
+	s.serverTransportStream.SetTrailer(md)
+}
+
+// read receives the frames of the connection until it fails or done is closed. The method is canceled once the connection is closed,
+// so that methods that only send messages stop when the client goes away.
+func (s *webSocketStream) read(cancel context.CancelFunc, done <-chan struct{}) {
+	defer close(s.frames)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(s.conn, &data); err != nil {
+			s.readErr = err
+			cancel()
+			return
+		}
+		select {
+		case s.frames <- data:
+		case <-done:
+			return
+		}
+	}
+}
+
+// RecvMsg decodes the next frame into m, it returns io.EOF once the client has closed the connection.
+func (s *webSocketStream) RecvMsg(m interface{}) error {
+	data, ok := <-s.frames
+	if !ok {
+		switch s.readErr {
+		case io.EOF:
+			return io.EOF
+		case websocket.ErrFrameTooLarge:
+			return status.Error(codes.ResourceExhausted, "frame exceeds the maximum request body size")
+		}
+		return status.Error(codes.Canceled, "the WebSocket connection is closed")
+	}
+	s.frame++
+	if err := unmarshalerFor(s.httpServerOpts.unmarshaler, m).Unmarshal(bytes.NewReader(data), m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "frame %d: %v", s.frame, err)
+	}
+	return s.httpServerOpts.validate(s.ctx, s.methodName, m)
+}
+
+// write sends data as one text frame.
+func (s *webSocketStream) write(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return websocket.Message.Send(s.conn, string(data))
+}
+
+func (s *webSocketStream) SendMsg(m interface{}) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := marshalerFor(s.marshaler, m).Marshal(buf, m); err != nil {
+		return err
+	}
+	return s.write(buf.Bytes())
+}
+
+func webSocketHandler(endpoint string, grpcServer interface{}, streamDesc *grpc.StreamDesc, httpServerOpts *serverOpts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isWebSocketUpgrade(r) {
+			w.Header().Set("Upgrade", "websocket")
+			http.Error(w, "method "+streamDesc.StreamName+" is only served over WebSocket", http.StatusUpgradeRequired)
+			return
+		}
+		server := websocket.Server{
+			Handshake: httpServerOpts.checkWebSocketOrigin,
+			Handler: func(conn *websocket.Conn) {
+				if httpServerOpts.maxRequestBodySize > 0 {
+					conn.MaxPayloadBytes = int(httpServerOpts.maxRequestBodySize)
+				}
+				ctx, cancel := context.WithCancel(incomingContext(r.Context(), r, httpServerOpts))
+				defer cancel()
+				stream := &webSocketStream{
+					serverTransportStream: &serverTransportStream{method: endpoint},
+					conn:                  conn,
+					httpServerOpts:        httpServerOpts,
+					methodName:            streamDesc.StreamName,
+					marshaler:             httpServerOpts.requestMarshaler(r),
+					frames:                make(chan []byte),
+				}
+				stream.ctx = grpc.NewContextWithServerTransportStream(ctx, stream.serverTransportStream)
+				done := make(chan struct{})
+				defer close(done)
+				go stream.read(cancel, done)
+
+				start := time.Now()
+				err := contextError(streamDesc.Handler(grpcServer, stream))
+				httpServerOpts.metrics.observeRPC(streamDesc.StreamName, status.Code(err), time.Since(start))
+				if err != nil {
+					resp, _ := json.Marshal(struct {
+						Error ErrorBody `json:"error"`
+					}{statusBody(status.Convert(err), httpServerOpts)})
+					stream.write(resp)
+				}
+				stream.mu.Lock()
+				conn.Close()
+				stream.mu.Unlock()
+			},
+		}
+		server.ServeHTTP(hijacker{w}, r)
+	}
+}
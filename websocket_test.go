@@ -0,0 +1,276 @@
+package grpcj
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/websocket"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type runningSumServer struct{}
+
+// RunningSum sends the sum of all the requests received so far after each request, it fails when a request has a negative NumOne.
+func (s *runningSumServer) RunningSum(stream RunningSumService_RunningSumServer) error {
+	var sum int64
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.NumOne < 0 {
+			st, _ := status.New(codes.InvalidArgument, "negative number").WithDetails(&errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "num_one", Description: "must not be negative"}},
+			})
+			return st.Err()
+		}
+		sum += req.NumOne + req.NumTwo
+		if err := stream.Send(&addResponse{Sum: sum}); err != nil {
+			return err
+		}
+	}
+}
+
+// The following mirrors the code generated by protoc-gen-go-grpc for a bidirectional-streaming method.
+
+type RunningSumService_RunningSumServer interface {
+	Send(*addResponse) error
+	Recv() (*addRequest, error)
+	grpc.ServerStream
+}
+
+type runningSumServiceRunningSumServer struct {
+	grpc.ServerStream
+}
+
+func (x *runningSumServiceRunningSumServer) Send(m *addResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *runningSumServiceRunningSumServer) Recv() (*addRequest, error) {
+	m := new(addRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _RunningSumService_RunningSum_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(*runningSumServer).RunningSum(&runningSumServiceRunningSumServer{stream})
+}
+
+var runningSumServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.RunningSumService",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RunningSum",
+			Handler:       _RunningSumService_RunningSum_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func TestWebSocket(t *testing.T) {
+	// Metrics and compression wrap the ResponseWriter, the connection must still be hijacked through them.
+	serverHTTP, err := NewServer(&runningSumServer{}, ServiceDesc(&runningSumServiceDesc), WebSocket(true),
+		Metrics(prometheus.NewRegistry()), Compression(-1))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	ts := httptest.NewServer(serverHTTP.Handler)
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/RunningSum"
+
+	dial := func(t *testing.T, origin string) *websocket.Conn {
+		config, err := websocket.NewConfig(wsURL, ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.Header.Set("Accept-Encoding", "gzip")
+		if origin != "" {
+			config.Header.Set("Origin", origin)
+		}
+		conn, err := websocket.DialConfig(config)
+		if err != nil {
+			t.Fatalf("Error to dial, Error:%s", err)
+		}
+		return conn
+	}
+	receive := func(t *testing.T, conn *websocket.Conn) string {
+		var frame string
+		if err := websocket.Message.Receive(conn, &frame); err != nil {
+			t.Fatalf("Error to receive a frame, Error:%s", err)
+		}
+		return frame
+	}
+	errorCode := func(t *testing.T, frame string) string {
+		var resp struct {
+			Error ErrorBody `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(frame), &resp); err != nil {
+			t.Fatalf("Error frame %q is not JSON, Error:%s", frame, err)
+		}
+		return resp.Error.Code
+	}
+
+	t.Run("frames", func(t *testing.T) {
+		conn := dial(t, "")
+		defer conn.Close()
+		for _, test := range []struct{ req, want string }{
+			{`{"numOne": 1, "numTwo": 2}`, `{"sum":3}`},
+			{`{"numOne": 4}`, `{"sum":7}`},
+		} {
+			if err := websocket.Message.Send(conn, test.req); err != nil {
+				t.Fatal(err)
+			}
+			if frame := receive(t, conn); frame != test.want {
+				t.Errorf("Expected frame %s, got %s", test.want, frame)
+			}
+		}
+	})
+
+	t.Run("error frame", func(t *testing.T) {
+		conn := dial(t, "")
+		defer conn.Close()
+		websocket.Message.Send(conn, `{"numOne": -1}`)
+		frame := receive(t, conn)
+		if code := errorCode(t, frame); code != "INVALID_ARGUMENT" {
+			t.Errorf("Expected code INVALID_ARGUMENT, got %s", code)
+		}
+		if want := `"@type":"type.googleapis.com/google.rpc.BadRequest"`; !strings.Contains(frame, want) {
+			t.Errorf("Expected the status details in the error frame, got %s", frame)
+		}
+		if err := websocket.Message.Receive(conn, &frame); err != io.EOF {
+			t.Errorf("Expected the connection to be closed after the error frame, got %q, %v", frame, err)
+		}
+	})
+
+	t.Run("invalid frame", func(t *testing.T) {
+		conn := dial(t, "")
+		defer conn.Close()
+		websocket.Message.Send(conn, `{"numOne":`)
+		frame := receive(t, conn)
+		if code := errorCode(t, frame); code != "INVALID_ARGUMENT" || !strings.Contains(frame, "frame 1") {
+			t.Errorf("Expected an INVALID_ARGUMENT error for frame 1, got %s", frame)
+		}
+	})
+
+	t.Run("origin", func(t *testing.T) {
+		conn := dial(t, ts.URL)
+		conn.Close()
+
+		config, _ := websocket.NewConfig(wsURL, "http://evil.example")
+		config.Header.Set("Origin", "http://evil.example")
+		if conn, err := websocket.DialConfig(config); err == nil {
+			conn.Close()
+			t.Error("Expected the handshake from another origin to be rejected")
+		}
+	})
+
+	t.Run("not a handshake", func(t *testing.T) {
+		resp, err := ts.Client().Get(ts.URL + "/RunningSum")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUpgradeRequired {
+			t.Errorf("Expected status 426, got %d", resp.StatusCode)
+		}
+	})
+}
+
+type eventStreamServer struct{}
+
+// PostponeAll sends back each event received an hour later.
+func (s *eventStreamServer) PostponeAll(stream grpc.ServerStream) error {
+	for {
+		event := new(eventMessage)
+		if err := stream.RecvMsg(event); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := stream.SendMsg(&eventMessage{Name: event.Name, Created: event.Created.Add(time.Hour)}); err != nil {
+			return err
+		}
+	}
+}
+
+var eventStreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.EventStreamService",
+	HandlerType: (*interface{})(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "PostponeAll",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*eventStreamServer).PostponeAll(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func TestWebSocketGogo(t *testing.T) {
+	serverHTTP, err := NewServer(&eventStreamServer{}, ServiceDesc(&eventStreamServiceDesc), WebSocket(true))
+	if err != nil {
+		t.Fatalf("Error to create the server, Error:%s", err)
+	}
+	ts := httptest.NewServer(serverHTTP.Handler)
+	defer ts.Close()
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/PostponeAll", "", ts.URL)
+	if err != nil {
+		t.Fatalf("Error to dial, Error:%s", err)
+	}
+	defer conn.Close()
+
+	if err := websocket.Message.Send(conn, `{"name":"launch","created":"2024-01-01T10:00:00Z"}`); err != nil {
+		t.Fatal(err)
+	}
+	var frame string
+	if err := websocket.Message.Receive(conn, &frame); err != nil {
+		t.Fatalf("Error to receive a frame, Error:%s", err)
+	}
+	if want := `{"name":"launch","created":"2024-01-01T11:00:00.000Z"}`; frame != want {
+		t.Errorf("Expected frame %s, got %s", want, frame)
+	}
+}
+
+func TestWebSocketRoutes(t *testing.T) {
+	routes, err := Routes(&runningSumServer{}, ServiceDesc(&runningSumServiceDesc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 0 {
+		t.Errorf("Expected bidirectional-streaming methods to be skipped without WebSocket, got %v", routes)
+	}
+
+	routes, err = Routes(&runningSumServer{}, ServiceDesc(&runningSumServiceDesc), WebSocket(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 {
+		t.Fatalf("Expected 1 route, got %v", routes)
+	}
+	route := routes[0]
+	if !route.ClientStreaming || !route.ServerStreaming || len(route.HTTPMethods) != 1 || route.HTTPMethods[0] != "GET" {
+		t.Errorf("Unexpected route %+v", route)
+	}
+	if route.RequestType.String() != "*grpcj.addRequest" || route.ResponseType.String() != "*grpcj.addResponse" {
+		t.Errorf("Unexpected message types %s and %s", route.RequestType, route.ResponseType)
+	}
+}